@@ -0,0 +1,114 @@
+"use client";
+import { useState, Suspense } from "react";
+import { Button } from "@/components/ui/button";
+import Image from "next/image";
+import { toast } from "sonner";
+import { useRouter, useSearchParams } from "next/navigation";
+import {
+  Card,
+  CardContent,
+  CardDescription,
+  CardHeader,
+  CardTitle,
+} from "@/components/ui/card";
+
+// Opened from the mail sent to the new address, the change happens on the click
+// so that mail scanners opening the link do not use it up
+function ChangeEmailPageHolder() {
+  const [isLoading, setIsLoading] = useState(false);
+  const [changedTo, setChangedTo] = useState<string | null>(null);
+  const router = useRouter();
+
+  const searchParams = useSearchParams();
+  const token = searchParams.get("token");
+  const id = searchParams.get("id");
+
+  async function confirm() {
+    setIsLoading(true);
+
+    try {
+      const response = await fetch(
+        `${process.env.NEXT_PUBLIC_AUTH_URL}/api/auth/change-email/confirm`,
+        {
+          method: "POST",
+          headers: { "Content-Type": "application/json" },
+          body: JSON.stringify({ token, id }),
+          credentials: "include",
+        }
+      );
+      const data = await response.json();
+
+      if (response.ok) {
+        toast.success(data.message || "Email changed successfully");
+        setChangedTo(data.email);
+      } else {
+        toast.error(data.error || "Failed to change email");
+      }
+    } catch {
+      toast.error("Something went wrong. Try again later.");
+    } finally {
+      setIsLoading(false);
+    }
+  }
+
+  return (
+    <div className="flex flex-col items-center justify-center min-h-screen p-4 bg-linear-to-r from-blue-100 to-teal-100 dark:from-slate-800 dark:to-slate-900">
+      <Card className="w-full max-w-sm">
+        <CardHeader>
+          <CardTitle className="flex flex-col items-center gap-2">
+            <a
+              href="https://pclub.in"
+              className="flex flex-col items-center gap-2 font-medium"
+            >
+              <div className="flex size-8 items-center justify-center rounded-md">
+                <Image
+                  src="/pclub.png"
+                  alt="Programming Club Logo"
+                  width={60}
+                  height={60}
+                  className="rounded-2xl"
+                />
+              </div>
+              <span className="sr-only">Programming Club</span>
+            </a>
+          </CardTitle>
+          <CardDescription className="flex flex-col items-center gap-2">
+            <p>Programming Club IIT Kanpur</p>
+          </CardDescription>
+          <CardTitle className="text-2xl">Change Email</CardTitle>
+          <CardDescription>
+            {!token || !id
+              ? "This link is incomplete, please request the change again from your profile."
+              : changedTo
+                ? `Your account now uses ${changedTo}.`
+                : "Confirm to use this address for your account."}
+          </CardDescription>
+        </CardHeader>
+
+        <CardContent className="grid gap-4">
+          {token && id && !changedTo && (
+            <Button className="w-full" disabled={isLoading} onClick={confirm}>
+              {isLoading ? "Confirming..." : "Confirm New Email"}
+            </Button>
+          )}
+          <Button
+            type="button"
+            variant="outline"
+            className="w-full"
+            onClick={() => router.replace(changedTo ? "/profile" : "/login")}
+          >
+            {changedTo ? "Go to Profile" : "Back to Login"}
+          </Button>
+        </CardContent>
+      </Card>
+    </div>
+  );
+}
+
+export default function ChangeEmailPage() {
+  return (
+    <Suspense>
+      <ChangeEmailPageHolder />
+    </Suspense>
+  );
+}
@@ -0,0 +1,179 @@
+"use client";
+import { FormEvent, useState, useRef, Suspense } from "react";
+import ReCAPTCHA from "react-google-recaptcha";
+import { Button } from "@/components/ui/button";
+import { Input } from "@/components/ui/input";
+import { Label } from "@/components/ui/label";
+import Image from "next/image";
+import { toast } from "sonner";
+import { useRouter, useSearchParams } from "next/navigation";
+import { useGContext } from "@/components/ContextProvider";
+import {
+  Card,
+  CardContent,
+  CardDescription,
+  CardHeader,
+  CardTitle,
+} from "@/components/ui/card";
+
+// Without the token: asks for the email and mails the link
+// With the token (opened from the mail): logs in on the click, the link works only once
+function MagicLoginPageHolder() {
+  const [isLoading, setIsLoading] = useState(false);
+  const router = useRouter();
+  const { setLoggedIn } = useGContext();
+
+  const siteKey = process.env.NEXT_PUBLIC_RECAPTCHA_SITE_KEY!;
+  const recaptchaRef = useRef<ReCAPTCHA>(null);
+
+  const searchParams = useSearchParams();
+  const token = searchParams.get("token");
+  const id = searchParams.get("id");
+
+  async function requestLink(event: FormEvent<HTMLFormElement>) {
+    event.preventDefault();
+    setIsLoading(true);
+
+    try {
+      const captcha = await recaptchaRef.current?.executeAsync();
+      if (!captcha) {
+        toast.error("Error in captcha validation");
+        return;
+      }
+      const formData = new FormData(event.currentTarget);
+      const email = formData.get("email");
+
+      const response = await fetch(
+        `${process.env.NEXT_PUBLIC_AUTH_URL}/api/auth/magic-link`,
+        {
+          method: "POST",
+          headers: { "Content-Type": "application/json" },
+          body: JSON.stringify({ email, token: captcha }),
+        }
+      );
+      const data = await response.json();
+
+      if (response.ok) {
+        toast.success(data.message || "Login link sent.");
+      } else {
+        toast.error(data.error || "Something went wrong.");
+      }
+    } catch {
+      toast.error("Something went wrong. Try again later.");
+    } finally {
+      setIsLoading(false);
+      recaptchaRef.current?.reset();
+    }
+  }
+
+  async function login() {
+    setIsLoading(true);
+
+    try {
+      const response = await fetch(
+        `${process.env.NEXT_PUBLIC_AUTH_URL}/api/auth/magic-link/verify`,
+        {
+          method: "POST",
+          headers: { "Content-Type": "application/json" },
+          body: JSON.stringify({ token, id }),
+          credentials: "include",
+        }
+      );
+      const data = await response.json();
+
+      if (response.ok) {
+        toast.success(data.message);
+        if (data.mfaRequired) {
+          router.replace("/login");
+          return;
+        }
+        setLoggedIn(true); // global context
+        router.replace(process.env.NEXT_PUBLIC_PROFILE_URL || "/");
+      } else {
+        toast.error(data.error || "Login failed");
+      }
+    } catch {
+      toast.error("Something went wrong. Try again later.");
+    } finally {
+      setIsLoading(false);
+    }
+  }
+
+  return (
+    <div className="flex flex-col items-center justify-center min-h-screen p-4 bg-linear-to-r from-blue-100 to-teal-100 dark:from-slate-800 dark:to-slate-900">
+      <Card className="w-full max-w-sm">
+        <CardHeader>
+          <CardTitle className="flex flex-col items-center gap-2">
+            <a
+              href="https://pclub.in"
+              className="flex flex-col items-center gap-2 font-medium"
+            >
+              <div className="flex size-8 items-center justify-center rounded-md">
+                <Image
+                  src="/pclub.png"
+                  alt="Programming Club Logo"
+                  width={60}
+                  height={60}
+                  className="rounded-2xl"
+                />
+              </div>
+              <span className="sr-only">Programming Club</span>
+            </a>
+          </CardTitle>
+          <CardDescription className="flex flex-col items-center gap-2">
+            <p>Programming Club IIT Kanpur</p>
+          </CardDescription>
+          <CardTitle className="text-2xl">Login Link</CardTitle>
+          <CardDescription>
+            {token && id
+              ? "Continue to log in to your account."
+              : "We will email you a link to log in without the password."}
+          </CardDescription>
+        </CardHeader>
+
+        <CardContent>
+          {token && id ? (
+            <Button className="w-full" disabled={isLoading} onClick={login}>
+              {isLoading ? "Verifying..." : "Log In"}
+            </Button>
+          ) : (
+            <form onSubmit={requestLink} className="grid gap-4">
+              <div className="grid gap-2">
+                <Label htmlFor="email">Email</Label>
+                <Input
+                  id="email"
+                  name="email"
+                  type="email"
+                  placeholder="@iitk.ac.in"
+                  required
+                />
+              </div>
+
+              <ReCAPTCHA sitekey={siteKey} ref={recaptchaRef} size="invisible" />
+
+              <Button type="submit" className="w-full" disabled={isLoading}>
+                {isLoading ? "Sending..." : "Send Login Link"}
+              </Button>
+              <Button
+                type="button"
+                variant="outline"
+                className="w-full"
+                onClick={() => window.location.href = "/login"}
+              >
+                Back to Login
+              </Button>
+            </form>
+          )}
+        </CardContent>
+      </Card>
+    </div>
+  );
+}
+
+export default function MagicLoginPage() {
+  return (
+    <Suspense>
+      <MagicLoginPageHolder />
+    </Suspense>
+  );
+}
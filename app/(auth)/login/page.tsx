@@ -160,6 +160,13 @@ function LoginPageHolder() {
             <Button type="submit" className="w-full" disabled={isLoading}>
               {isLoading ? "Verifying..." : "Login"}
             </Button>
+            <Button
+              variant="link"
+              asChild
+              className="-mt-2 h-auto text-sm underline-offset-4"
+            >
+              <a href="/login/magic">Email me a login link instead</a>
+            </Button>
 
             {/* Divider with OR text */}
             <div className="relative -my-2">
@@ -0,0 +1,138 @@
+"use client";
+import { useEffect, useState, Suspense } from "react";
+import { Button } from "@/components/ui/button";
+import Image from "next/image";
+import { toast } from "sonner";
+import { useRouter, useSearchParams } from "next/navigation";
+import {
+    Card,
+    CardContent,
+    CardDescription,
+    CardHeader,
+    CardTitle,
+} from "@/components/ui/card";
+
+type ConsentDetails = {
+    client: { clientId: string; name: string };
+    scopes: { scope: string; description: string }[];
+};
+
+// Consent screen of "Login with Compass", the auth server sends the browser here from /api/oidc/authorize
+function ConsentPageHolder() {
+    const [details, setDetails] = useState<ConsentDetails | null>(null);
+    const [error, setError] = useState<string | null>(null);
+    const [isLoading, setIsLoading] = useState(false);
+    const router = useRouter();
+    const searchParams = useSearchParams();
+    const query = searchParams.toString();
+
+    useEffect(() => {
+        async function load() {
+            try {
+                const response = await fetch(
+                    `${process.env.NEXT_PUBLIC_AUTH_URL}/api/oidc/consent?${query}`,
+                    { credentials: "include" }
+                );
+                if (response.status === 401) {
+                    router.replace(`/login?callbackUrl=${encodeURIComponent(`/oauth/consent?${query}`)}`);
+                    return;
+                }
+                const data = await response.json();
+                if (data.redirect) {
+                    // Already allowed, or the request was invalid for the app
+                    window.location.href = data.redirect;
+                    return;
+                }
+                if (!response.ok) {
+                    setError(data.error || "Invalid request");
+                    return;
+                }
+                setDetails(data);
+            } catch {
+                setError("Something went wrong.");
+            }
+        }
+        load();
+    }, [query, router]);
+
+    async function answer(approve: boolean) {
+        setIsLoading(true);
+        try {
+            const response = await fetch(`${process.env.NEXT_PUBLIC_AUTH_URL}/api/oidc/consent`, {
+                method: "POST",
+                credentials: "include",
+                headers: { "Content-Type": "application/json" },
+                body: JSON.stringify({ ...Object.fromEntries(searchParams.entries()), approve }),
+            });
+            const data = await response.json();
+            if (data.redirect) {
+                window.location.href = data.redirect;
+                return;
+            }
+            toast.error(data.error || "Something went wrong.");
+        } catch {
+            toast.error("Something went wrong.");
+        }
+        setIsLoading(false);
+    }
+
+    return (
+        <div className="flex flex-col items-center justify-center min-h-screen p-4 bg-linear-to-r from-blue-100 to-teal-100 dark:from-slate-800 dark:to-slate-900">
+            <Card className="w-full max-w-sm">
+                <CardHeader>
+                    <CardTitle className="flex flex-col items-center gap-2">
+                        <a href="https://pclub.in" className="flex flex-col items-center gap-2 font-medium">
+                            <div className="flex size-8 items-center justify-center rounded-md">
+                                <Image src="/pclub.png" alt="Logo" width={60} height={60} className="rounded-2xl" />
+                            </div>
+                        </a>
+                    </CardTitle>
+                    <CardDescription className="flex flex-col items-center gap-2">
+                        <p>Programming Club IIT Kanpur</p>
+                    </CardDescription>
+                    {error ? (
+                        <>
+                            <CardTitle className="text-destructive text-2xl pt-2">Invalid Request</CardTitle>
+                            <CardDescription>{error}</CardDescription>
+                        </>
+                    ) : (
+                        <>
+                            <CardTitle className="text-2xl pt-2">
+                                {details ? `Login to ${details.client.name}` : "Loading..."}
+                            </CardTitle>
+                            {details && (
+                                <CardDescription>
+                                    {details.client.name} wants to use your Compass account to:
+                                </CardDescription>
+                            )}
+                        </>
+                    )}
+                </CardHeader>
+
+                {details && !error && (
+                    <CardContent className="grid gap-4">
+                        <ul className="list-disc pl-5 text-sm">
+                            {details.scopes.map((s) => (
+                                <li key={s.scope}>{s.description}</li>
+                            ))}
+                        </ul>
+                        <Button className="w-full" disabled={isLoading} onClick={() => answer(true)}>
+                            {isLoading ? "Redirecting..." : "Allow"}
+                        </Button>
+                        <Button variant="outline" className="w-full" disabled={isLoading} onClick={() => answer(false)}>
+                            Deny
+                        </Button>
+                    </CardContent>
+                )}
+            </Card>
+        </div>
+    );
+}
+
+export default function ConsentPage() {
+    return (
+        <Suspense fallback={<div>Loading...</div>}>
+            <ConsentPageHolder />
+        </Suspense>
+    );
+}
@@ -0,0 +1,83 @@
+"use client";
+import { Suspense } from "react";
+import { Button } from "@/components/ui/button";
+import Image from "next/image";
+import { useSearchParams } from "next/navigation";
+import {
+  Card,
+  CardContent,
+  CardDescription,
+  CardHeader,
+  CardTitle,
+} from "@/components/ui/card";
+
+// Opened from the mail, the link keeps working till it expires so scanners opening it do no harm
+function ExportPageHolder() {
+  const searchParams = useSearchParams();
+  const token = searchParams.get("token");
+  const id = searchParams.get("id");
+
+  const downloadUrl =
+    token && id
+      ? `${process.env.NEXT_PUBLIC_AUTH_URL}/api/profile/export/${encodeURIComponent(id)}/download?token=${encodeURIComponent(token)}`
+      : null;
+
+  return (
+    <div className="flex flex-col items-center justify-center min-h-screen p-4 bg-linear-to-r from-blue-100 to-teal-100 dark:from-slate-800 dark:to-slate-900">
+      <Card className="w-full max-w-sm">
+        <CardHeader>
+          <CardTitle className="flex flex-col items-center gap-2">
+            <a
+              href="https://pclub.in"
+              className="flex flex-col items-center gap-2 font-medium"
+            >
+              <div className="flex size-8 items-center justify-center rounded-md">
+                <Image
+                  src="/pclub.png"
+                  alt="Programming Club Logo"
+                  width={60}
+                  height={60}
+                  className="rounded-2xl"
+                />
+              </div>
+              <span className="sr-only">Programming Club</span>
+            </a>
+          </CardTitle>
+          <CardDescription className="flex flex-col items-center gap-2">
+            <p>Programming Club IIT Kanpur</p>
+          </CardDescription>
+          <CardTitle className="text-2xl">Your Data</CardTitle>
+          <CardDescription>
+            {downloadUrl
+              ? "Your archive is ready. It has your account, profile, contributions and uploaded images."
+              : "The link is incomplete, please open it again from the email."}
+          </CardDescription>
+        </CardHeader>
+
+        <CardContent className="grid gap-4">
+          {downloadUrl && (
+            <Button asChild className="w-full">
+              <a href={downloadUrl}>Download</a>
+            </Button>
+          )}
+          <Button
+            type="button"
+            variant="outline"
+            className="w-full"
+            onClick={() => window.location.href = "/profile"}
+          >
+            Back to Profile
+          </Button>
+        </CardContent>
+      </Card>
+    </div>
+  );
+}
+
+export default function ExportPage() {
+  return (
+    <Suspense>
+      <ExportPageHolder />
+    </Suspense>
+  );
+}
@@ -24,6 +24,7 @@ import type { Profile } from "@/app/(auth)/profile/page";
 import { courses, departmentNameMap, halls } from "@/components/Constant";
 import {
   AlertDeleteProfileInfo,
+  AlertExportProfileInfo,
   AlertVisibilityProfileInfo,
 } from "./ProfileAction";
 
@@ -126,6 +127,7 @@ export function EditableProfileCard({
                 <Edit className="h-4 w-4" />
                 Edit
               </Button>
+              <AlertExportProfileInfo />
               <AlertDeleteProfileInfo />
               <AlertVisibilityProfileInfo
                 currentVisibility={visibility}
@@ -9,7 +9,7 @@ import {
   DialogFooter,
 } from "@/components/ui/dialog";
 import { Button } from "@/components/ui/button";
-import { Eye, EyeClosed, Trash } from "lucide-react";
+import { Download, Eye, EyeClosed, Trash } from "lucide-react";
 import { cn } from "@/lib/utils";
 import { useGContext } from "../ContextProvider";
 import { toast } from "sonner";
@@ -233,3 +233,62 @@ export function AlertVisibilityProfileInfo({
     </Dialog>
   );
 }
+
+// Takeout of the user's data, the download link is mailed once the archive is ready
+export function AlertExportProfileInfo() {
+  const { setGlobalLoading } = useGContext();
+
+  const requestExport = async () => {
+    try {
+      setGlobalLoading(true);
+      const response = await fetch(
+        `${process.env.NEXT_PUBLIC_AUTH_URL}/api/profile/export`,
+        {
+          method: "POST",
+          credentials: "include",
+        }
+      );
+      const data = await response.json();
+      if (!response.ok) {
+        toast(data.error || "Unable to request the export, please try again later.");
+        return;
+      }
+      toast(data.message);
+    } catch {
+      toast("Unable to request the export, please try again later.");
+    } finally {
+      setGlobalLoading(false);
+    }
+  };
+  return (
+    <Dialog>
+      <DialogTrigger asChild>
+        <Button variant="outline" onClick={() => {}}>
+          <Download />
+        </Button>
+      </DialogTrigger>
+      <DialogContent>
+        <DialogHeader>
+          <DialogTitle>Download your data</DialogTitle>
+          <DialogDescription>
+            We will put together your account and profile details, the
+            locations, reviews and notices you contributed and the images you
+            uploaded into a zip file.
+            <br />
+            <br />
+            You will receive an email with the download link once it is ready,
+            the link works for 48 hours.
+          </DialogDescription>
+        </DialogHeader>
+        <DialogFooter>
+          <DialogClose asChild>
+            <Button onClick={requestExport}>Request</Button>
+          </DialogClose>
+          <DialogClose asChild>
+            <Button variant="outline">Cancel</Button>
+          </DialogClose>
+        </DialogFooter>
+      </DialogContent>
+    </Dialog>
+  );
+}
@@ -46,10 +46,11 @@ export function Step1Register({ onSuccess }: Step1RegisterProps) {
       const formData = new FormData(formRef.current!);
       const email = formData.get("email")?.toString().toLowerCase();
       const password = formData.get("password");
+      const inviteCode = formData.get("inviteCode")?.toString().trim() || "";
 
-      // Only allow IITK email addresses
-      if (typeof email !== "string" || !email.endsWith("@iitk.ac.in")) {
-        toast.error("Please use a valid IIT Kanpur email address.");
+      // Other emails join as visitors, the server checks the invite code or the allowed domains
+      if (typeof email !== "string") {
+        toast.error("Please enter a valid email address.");
         setIsLoading(false);
         return;
       }
@@ -58,7 +59,7 @@ export function Step1Register({ onSuccess }: Step1RegisterProps) {
         {
           method: "POST",
           headers: { "Content-Type": "application/json" },
-          body: JSON.stringify({ email, password, token }),
+          body: JSON.stringify({ email, password, token, inviteCode }),
         }
       );
 
@@ -123,7 +124,7 @@ export function Step1Register({ onSuccess }: Step1RegisterProps) {
               id="email"
               name="email"
               type="email"
-              placeholder="@iitk.ac.in"
+              placeholder="@iitk.ac.in (or your email with an invite)"
               required
             />
           </div>
@@ -139,6 +140,16 @@ export function Step1Register({ onSuccess }: Step1RegisterProps) {
             />
           </div>
 
+          <div className="grid gap-2">
+            <Label htmlFor="inviteCode">Invite Code (visitors only)</Label>
+            <Input
+              id="inviteCode"
+              name="inviteCode"
+              type="text"
+              placeholder="not needed with an IITK email"
+            />
+          </div>
+
           <div className="flex items-start space-x-2 text-sm text-gray-600 dark:text-gray-400">
             <input
               id="privacy"
@@ -18,6 +18,7 @@ export function middleware(request: NextRequest) {
     if (!pathname.startsWith("/login") && !pathname.startsWith("/signup") && 
         !pathname.startsWith("/forgot-password") && !pathname.startsWith("/reset-password") &&
         !pathname.startsWith("/privacy-policy") && !pathname.startsWith("/profile") &&
+        !pathname.startsWith("/oauth") && !pathname.startsWith("/change-email") &&
         !pathname.startsWith("/_next") && !pathname.startsWith("/api") &&
         !pathname.startsWith("/public")) {
       // Redirect to login if accessing non-auth routes on auth subdomain
@@ -2,6 +2,7 @@ package assets
 
 import (
 	"compass/middleware"
+	"compass/model"
 
 	"github.com/gin-gonic/gin"
 )
@@ -40,13 +41,14 @@ func Router(r *gin.Engine) {
 	// protected.Use(middleware.UserAuthenticator, middleware.EmailVerified, noCacheMiddleware())
 	{
 		protected.Static("/pfp", "./assets/pfp")
-		protected.POST("/assets", uploadAsset)
+		// Images are uploaded only to be attached to a review, location or notice
+		protected.POST("/assets", middleware.Require(model.PermReviewCreate, model.PermLocationContribute, model.PermNoticePublish), uploadAsset)
 	}
 
-	// Admin only routes
+	// Admin only routes, images waiting for moderation
 	admin := r.Group("/")
-	admin.Use(middleware.UserAuthenticator, middleware.EmailVerified, middleware.AdminAuthenticator)
-	// admin.Use(middleware.UserAuthenticator, middleware.EmailVerified, middleware.AdminAuthenticator, noCacheMiddleware())
+	admin.Use(middleware.UserAuthenticator, middleware.EmailVerified, middleware.Require(model.PermReviewModerate, model.PermLocationApprove))
+	// admin.Use(middleware.UserAuthenticator, middleware.EmailVerified, middleware.Require(model.PermReviewModerate, model.PermLocationApprove), noCacheMiddleware())
 	{
 		admin.Static("/tmp", "./assets/tmp")
 	}
@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"compass/connections"
+	"compass/middleware"
+	"compass/model"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Enough for a few scripts, stops a leaked session from minting tokens without end
+const maxAccessTokens = 20
+
+// Active personal access tokens of the user
+func listAccessTokensHandler(c *gin.Context) {
+	var tokens []model.PersonalAccessToken
+	if err := connections.DB.
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", c.MustGet("userID").(uuid.UUID), time.Now()).
+		Order("created_at DESC").
+		Find(&tokens).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tokens"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tokens": tokens})
+}
+
+// Creates a token, it is returned only in this response
+func createAccessTokenHandler(c *gin.Context) {
+	var req CreateAccessTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	// A token can not hold more than the user
+	granted, err := middleware.UserPermissions(userID, model.Role(c.GetInt("userRole")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify permissions"})
+		return
+	}
+	for _, permission := range req.Permissions {
+		if !granted[permission] {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You do not have the permission " + permission})
+			return
+		}
+		if model.IsPrivileged(permission) && !c.GetBool("mfa") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Two factor authentication is required for this action", "mfaRequired": true})
+			return
+		}
+	}
+
+	var active int64
+	if err := connections.DB.Model(&model.PersonalAccessToken{}).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Count(&active).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if active >= maxAccessTokens {
+		c.JSON(http.StatusConflict, gin.H{"error": "Too many active tokens, revoke an old one first"})
+		return
+	}
+
+	token, hash, err := middleware.GeneratePersonalToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token"})
+		return
+	}
+	accessToken := model.PersonalAccessToken{
+		UserID:      userID,
+		Name:        req.Name,
+		Prefix:      token[:len(middleware.PersonalTokenPrefix)+6],
+		TokenHash:   hash,
+		Permissions: req.Permissions,
+		ExpiresAt:   time.Now().AddDate(0, 0, req.ExpiresInDays),
+	}
+	if err := connections.DB.Create(&accessToken).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"message":     "Copy the token now, it will not be shown again",
+		"token":       token,
+		"accessToken": accessToken,
+	})
+}
+
+func revokeAccessTokenHandler(c *gin.Context) {
+	tokenID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token ID"})
+		return
+	}
+	result := connections.DB.
+		Model(&model.PersonalAccessToken{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", tokenID, c.MustGet("userID").(uuid.UUID)).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke token"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Token not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Token revoked"})
+}
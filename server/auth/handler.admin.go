@@ -0,0 +1,388 @@
+package auth
+
+import (
+	"compass/connections"
+	"compass/middleware"
+	"compass/model"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const adminUserPageSize = 20
+
+// Admin user management, every action is written into the system logs (model.Logs)
+
+// adminLog builds the log entry for an action of the current admin on the target user
+func adminLog(c *gin.Context, target uuid.UUID, title string, description string) model.Logs {
+	actorID := c.MustGet("userID").(uuid.UUID)
+	return model.Logs{
+		Title:       title,
+		Description: description,
+		ActionTaker: model.Role(c.GetInt("userRole")).BaseRoleName(),
+		ActorID:     &actorID,
+		TargetID:    &target,
+	}
+}
+
+// parses the target user from the path, admins are not allowed to act on their own account
+func adminTarget(c *gin.Context) (uuid.UUID, bool) {
+	targetID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return uuid.Nil, false
+	}
+	if targetID == c.MustGet("userID").(uuid.UUID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You can not perform this action on your own account"})
+		return uuid.Nil, false
+	}
+	return targetID, true
+}
+
+// roleRank orders the users for the admin actions, the base role raised by the assigned admin roles
+func roleRank(userID uuid.UUID) (int, error) {
+	var user model.User
+	if err := connections.DB.Select("user_id", "role").First(&user, "user_id = ?", userID).Error; err != nil {
+		return 0, err
+	}
+	var roles []string
+	if err := connections.DB.Model(&model.RoleAssignment{}).Where("user_id = ?", userID).Pluck("role_name", &roles).Error; err != nil {
+		return 0, err
+	}
+	rank := int(user.Role)
+	for _, role := range roles {
+		switch role {
+		case model.RoleNameSuperAdmin:
+			rank = max(rank, int(model.AdminRole)+1)
+		case model.RoleNameAdmin:
+			rank = max(rank, int(model.AdminRole))
+		}
+	}
+	return rank, nil
+}
+
+// outranksTarget refuses the action when the target has the same or a higher role than the admin
+func outranksTarget(c *gin.Context, targetID uuid.UUID) bool {
+	actorRank, err := roleRank(c.MustGet("userID").(uuid.UUID))
+	if err != nil {
+		adminActionError(c, err)
+		return false
+	}
+	targetRank, err := roleRank(targetID)
+	if err != nil {
+		adminActionError(c, err)
+		return false
+	}
+	if targetRank >= actorRank {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can not act on a user with the same or a higher role"})
+		return false
+	}
+	return true
+}
+
+// Search users by email or roll number
+func searchUsersHandler(c *gin.Context) {
+	query := strings.TrimSpace(strings.ToLower(c.Query("query")))
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	search := func() *gorm.DB {
+		db := connections.DB.
+			Model(&model.User{}).
+			Joins("LEFT JOIN profiles ON profiles.user_id = users.user_id AND profiles.deleted_at IS NULL")
+		if query != "" {
+			db = db.Where("users.email ILIKE ? OR profiles.roll_no = ?", "%"+query+"%", query)
+		}
+		return db
+	}
+
+	var total int64
+	if err := search().Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search users"})
+		return
+	}
+	var users []model.User
+	if err := search().
+		Select("users.*").
+		Preload("Profile").
+		Order("users.created_at DESC").
+		Limit(adminUserPageSize).
+		Offset((page - 1) * adminUserPageSize).
+		Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search users"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"users": users, "total": total, "page": page})
+}
+
+// User details along with the recent contributions, same as the user sees on the profile page
+func userDetailsHandler(c *gin.Context) {
+	targetID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	var user model.User
+	if err := connections.DB.
+		Model(&model.User{}).
+		Preload("Profile").
+		Preload("ContributedLocations", connections.RecentFiveLocations).
+		Preload("ContributedNotice", connections.RecentFiveNotices).
+		Preload("ContributedReview", connections.RecentFiveReviews).
+		Omit("password").
+		Where("user_id = ?", targetID).
+		First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User does not exist"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to fetch user at the moment"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+// Change the base role of the user (visitor / user / admin)
+func changeUserRoleHandler(c *gin.Context) {
+	var req ChangeUserRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	targetID, ok := adminTarget(c)
+	if !ok || !outranksTarget(c, targetID) {
+		return
+	}
+	role := model.UserRole
+	switch req.Role {
+	case model.RoleNameAdmin:
+		role = model.AdminRole
+	case model.RoleNameVisitor:
+		role = model.VisitorRole
+	}
+	updates := map[string]interface{}{"role": role}
+	if role >= model.UserRole {
+		// Users and admins do not expire, a promoted visitor keeps the account
+		updates["expires_at"] = nil
+	} else {
+		// A visitor keeps its expiry, a demoted user gets the one of a new visitor
+		updates["expires_at"] = gorm.Expr("COALESCE(expires_at, ?)", time.Now().AddDate(0, 0, visitorAccountDays()))
+	}
+
+	if err := connections.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.User{}).Where("user_id = ?", targetID).Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return connections.AddLog(tx, adminLog(c, targetID, "Role changed", fmt.Sprintf("Base role changed to %s", req.Role)))
+	}); err != nil {
+		adminActionError(c, err)
+		return
+	}
+	middleware.InvalidateAccount(targetID)
+	c.JSON(http.StatusOK, gin.H{"message": "Role updated"})
+}
+
+// Suspend blocks the login and all the existing sessions of the user
+func suspendUserHandler(c *gin.Context) {
+	var req SuspendUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reason is required"})
+		return
+	}
+	targetID, ok := adminTarget(c)
+	if !ok || !outranksTarget(c, targetID) {
+		return
+	}
+
+	if err := connections.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.User{}).Where("user_id = ?", targetID).
+			Updates(map[string]interface{}{"suspended_at": time.Now(), "suspend_reason": req.Reason})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return connections.AddLog(tx, adminLog(c, targetID, "User suspended", req.Reason))
+	}); err != nil {
+		adminActionError(c, err)
+		return
+	}
+	if err := middleware.RevokeAllSessions(targetID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "User suspended, but failed to revoke the sessions"})
+		return
+	}
+	middleware.InvalidateAccount(targetID)
+	c.JSON(http.StatusOK, gin.H{"message": "User suspended"})
+}
+
+func unsuspendUserHandler(c *gin.Context) {
+	targetID, ok := adminTarget(c)
+	if !ok || !outranksTarget(c, targetID) {
+		return
+	}
+	if err := connections.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.User{}).Where("user_id = ?", targetID).
+			Updates(map[string]interface{}{"suspended_at": nil, "suspend_reason": ""})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return connections.AddLog(tx, adminLog(c, targetID, "User unsuspended", ""))
+	}); err != nil {
+		adminActionError(c, err)
+		return
+	}
+	middleware.InvalidateAccount(targetID)
+	c.JSON(http.StatusOK, gin.H{"message": "User unsuspended"})
+}
+
+// Mark the email as verified, for users who can not receive the mail
+func forceVerifyUserHandler(c *gin.Context) {
+	targetID, ok := adminTarget(c)
+	if !ok {
+		return
+	}
+	if err := connections.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.User{}).Where("user_id = ?", targetID).Update("is_verified", true)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		// The pending otp is of no use now
+		if err := tx.Where("user_id = ? AND purpose = ?", targetID, model.PurposeVerifyEmail).Delete(&model.OneTimeToken{}).Error; err != nil {
+			return err
+		}
+		return connections.AddLog(tx, adminLog(c, targetID, "Email force verified", ""))
+	}); err != nil {
+		adminActionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "User verified"})
+}
+
+// Send the password reset mail to the user
+func forcePasswordResetHandler(c *gin.Context) {
+	targetID, ok := adminTarget(c)
+	if !ok || !outranksTarget(c, targetID) {
+		return
+	}
+	var user model.User
+	if err := connections.DB.Select("user_id", "email").First(&user, "user_id = ?", targetID).Error; err != nil {
+		adminActionError(c, err)
+		return
+	}
+	if err := sendPasswordResetMail(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send reset email"})
+		return
+	}
+	if err := connections.AddLog(connections.DB, adminLog(c, targetID, "Password reset mail sent", "")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Reset mail sent, but failed to write the log"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset mail sent"})
+}
+
+// Soft delete the user and the profile, the search clients drop it through the changelog
+func deleteUserHandler(c *gin.Context) {
+	targetID, ok := adminTarget(c)
+	if !ok || !outranksTarget(c, targetID) {
+		return
+	}
+	if err := connections.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("user_id = ?", targetID).Delete(&model.User{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := tx.Where("user_id = ?", targetID).Delete(&model.Profile{}).Error; err != nil {
+			return err
+		}
+		// Delete any pre existing log for user
+		if err := tx.Where("user_id = ?", targetID).Delete(&model.ChangeLog{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&model.ChangeLog{UserID: targetID, Action: model.Delete}).Error; err != nil {
+			return err
+		}
+		return connections.AddLog(tx, adminLog(c, targetID, "User deleted", ""))
+	}); err != nil {
+		adminActionError(c, err)
+		return
+	}
+	if err := middleware.RevokeAllSessions(targetID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "User deleted, but failed to revoke the sessions"})
+		return
+	}
+	middleware.InvalidateAccount(targetID)
+	c.JSON(http.StatusOK, gin.H{"message": "User deleted"})
+}
+
+// View as the user, read only and for a short time. Privileged users can not be viewed as,
+// their permissions would be exposed through the token.
+func impersonateUserHandler(c *gin.Context) {
+	targetID, ok := adminTarget(c)
+	if !ok {
+		return
+	}
+	var target model.User
+	if err := connections.DB.Select("user_id", "email", "role").Where("user_id = ?", targetID).First(&target).Error; err != nil {
+		adminActionError(c, err)
+		return
+	}
+	permissions, err := middleware.UserPermissions(targetID, target.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify permissions"})
+		return
+	}
+	for permission := range permissions {
+		if model.IsPrivileged(permission) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Can not view as a privileged user"})
+			return
+		}
+	}
+
+	adminID := c.MustGet("userID").(uuid.UUID)
+	token, expiresAt, err := middleware.ImpersonationToken(targetID, adminID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	// No impersonation without a record of it, the log is written before the token is handed out
+	if err := connections.AddLog(connections.DB, adminLog(c, targetID, "Impersonation started",
+		fmt.Sprintf("Viewing as %s till %s", target.Email, expiresAt.Format(time.RFC3339)))); err != nil {
+		logrus.Errorf("Failed to log the impersonation of %s by %s: %v", targetID, adminID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start viewing as the user"})
+		return
+	}
+	middleware.StartImpersonation(c, token)
+	c.JSON(http.StatusOK, gin.H{"message": "Viewing as " + target.Email, "expiresAt": expiresAt})
+}
+
+func adminActionError(c *gin.Context, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to perform the action"})
+}
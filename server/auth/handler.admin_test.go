@@ -0,0 +1,89 @@
+//go:build integration
+
+// Needs the postgres and rabbitmq of docker-compose: go test -tags integration ./auth
+package auth
+
+import (
+	"compass/connections"
+	"compass/model"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func createAdminTestUser(t *testing.T, role model.Role, assigned ...string) model.User {
+	t.Helper()
+	user := model.User{Email: "admin-" + uuid.NewString()[:8] + "@iitk.ac.in", IsVerified: true, Role: role}
+	if err := connections.DB.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	for _, name := range assigned {
+		if err := connections.DB.Create(&model.RoleAssignment{UserID: user.UserID, RoleName: name, AssignedBy: user.UserID}).Error; err != nil {
+			t.Fatalf("assign %s: %v", name, err)
+		}
+	}
+	t.Cleanup(func() {
+		connections.DB.Where("user_id = ?", user.UserID).Delete(&model.RoleAssignment{})
+		connections.DB.Unscoped().Where("user_id = ?", user.UserID).Delete(&model.Profile{})
+		connections.DB.Unscoped().Where("user_id = ?", user.UserID).Delete(&model.User{})
+	})
+	return user
+}
+
+func TestAdminCanNotActOnSuperAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	admin := createAdminTestUser(t, model.AdminRole)
+	superAdmin := createAdminTestUser(t, model.AdminRole, model.RoleNameSuperAdmin)
+
+	r := gin.New()
+	users := r.Group("/users", func(c *gin.Context) {
+		c.Set("userID", admin.UserID)
+		c.Set("userRole", int(admin.Role))
+	})
+	users.POST("/:id/role", changeUserRoleHandler)
+	users.POST("/:id/suspend", suspendUserHandler)
+	users.POST("/:id/reset-password", forcePasswordResetHandler)
+	users.DELETE("/:id", deleteUserHandler)
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{http.MethodPost, "/role", `{"role":"user"}`},
+		{http.MethodPost, "/suspend", `{"reason":"test"}`},
+		{http.MethodPost, "/reset-password", ``},
+		{http.MethodDelete, "", ``},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/users/"+superAdmin.UserID.String()+tt.path, strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s %s: %d %s, want 403", tt.method, tt.path, w.Code, w.Body)
+		}
+	}
+
+	var user model.User
+	if err := connections.DB.First(&user, "user_id = ?", superAdmin.UserID).Error; err != nil {
+		t.Fatalf("super admin is gone: %v", err)
+	}
+	if user.Role != model.AdminRole || user.SuspendedAt != nil {
+		t.Errorf("super admin changed: role %d, suspended at %v", user.Role, user.SuspendedAt)
+	}
+
+	// Another plain admin is of the same role
+	peer := createAdminTestUser(t, model.AdminRole)
+	req := httptest.NewRequest(http.MethodPost, "/users/"+peer.UserID.String()+"/suspend", strings.NewReader(`{"reason":"test"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("suspend an admin: %d %s, want 403", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,221 @@
+package auth
+
+import (
+	"compass/connections"
+	"compass/model"
+	"compass/password"
+	"compass/workers"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+var errEmailTaken = errors.New("email already in use")
+
+// Sends a fresh otp to an account which is not verified yet, the old one stops working
+func resendVerificationHandler(c *gin.Context) {
+	var req ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	email := strings.ToLower(req.Email)
+	attempts := []attempt{{resendEmailLimiter, email}, {forgotIPLimiter, c.ClientIP()}}
+	if throttled(c, attempts...) {
+		return
+	}
+	recordFailure(c, nil, attempts...)
+
+	// Same answer in every case, users should not know who is on the platform
+	const message = "If this email is registered and not verified, you will receive a new code."
+	var user model.User
+	if err := connections.DB.Select("user_id", "email", "is_verified").
+		Where("email = ?", email).First(&user).Error; err != nil || user.IsVerified {
+		c.JSON(http.StatusOK, gin.H{"message": message})
+		return
+	}
+
+	token, err := generateVerificationToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return
+	}
+	if err := issueToken(connections.DB, model.OneTimeToken{UserID: user.UserID, Purpose: model.PurposeVerifyEmail}, token, emailVerificationExpiry()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return
+	}
+	if err := sendVerificationMail(user.Email, user.UserID, token); err != nil {
+		logrus.Error("Failed to enqueue mail job:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
+
+// Step 1 of the email change: needs the password, the link goes to the new address
+// and the old address gets a heads up. Nothing changes till the link is opened.
+func changeEmailHandler(c *gin.Context) {
+	var req ChangeEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	newEmail := strings.ToLower(strings.TrimSpace(req.Email))
+	userID, exist := c.Get("userID")
+	if !exist {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	var user model.User
+	if err := connections.DB.Select("user_id", "email", "password", "role").
+		First(&user, "user_id = ?", userID.(uuid.UUID)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	// Same rule as the signup: students stay on the iitk domain, visitors on the allowed ones
+	if user.Role == model.VisitorRole {
+		if !visitorDomainAllowed(newEmail) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Please use an email address of an allowed domain"})
+			return
+		}
+	} else if !strings.HasSuffix(newEmail, "@iitk.ac.in") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Please use a valid IIT Kanpur email address"})
+		return
+	}
+	if newEmail == user.Email {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This is already your email"})
+		return
+	}
+
+	// The password check counts towards the login lockout of the account
+	attempts := []attempt{{loginEmailLimiter, user.Email}}
+	if throttled(c, attempts...) {
+		return
+	}
+	if match, _, err := password.Verify(req.Password, user.Password); !match {
+		if err != nil {
+			logrus.Errorf("Password hash of %s: %v", user.UserID, err)
+		}
+		if lockout := recordFailure(c, &user.UserID, attempts...); lockout > 0 {
+			tooManyAttempts(c, lockout)
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
+		return
+	}
+	resetFailures(attempts...)
+
+	if taken, err := emailTaken(connections.DB, newEmail); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	} else if taken {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email already in use"})
+		return
+	}
+
+	token, err := generateLinkToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	if err := issueToken(connections.DB, model.OneTimeToken{UserID: user.UserID, Purpose: model.PurposeChangeEmail, Email: newEmail}, token, time.Hour); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	link := fmt.Sprintf("%s/change-email?token=%s&id=%s", viper.GetString("frontend_url"), token, user.UserID.String())
+	jobs := []workers.MailJob{
+		{Type: "email_change", To: newEmail, Data: map[string]interface{}{"link": link}},
+		{Type: "email_change_notice", To: user.Email, Data: map[string]interface{}{"email": newEmail}},
+	}
+	for _, job := range jobs {
+		payload, _ := json.Marshal(job)
+		if err := workers.PublishJob(payload, model.MailQueue); err != nil {
+			logrus.Error("Failed to enqueue mail job:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send confirmation email"})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Please open the link sent to the new email to confirm the change"})
+}
+
+// Step 2 of the email change, the link proves the new address belongs to the user
+func confirmEmailChangeHandler(c *gin.Context) {
+	var req ConfirmEmailChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	userID, err := uuid.Parse(req.UserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	attempts := []attempt{{otpIPLimiter, c.ClientIP()}}
+	if throttled(c, attempts...) {
+		return
+	}
+
+	pending, err := consumeToken(userID, model.PurposeChangeEmail, req.Token)
+	if err != nil {
+		switch {
+		case errors.Is(err, errTokenExpired):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Link has expired, please request the change again"})
+		case errors.Is(err, errTokenInvalid), errors.Is(err, errTokenExhausted):
+			recordFailure(c, &userID, attempts...)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid link"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change email"})
+		}
+		return
+	}
+
+	if err := connections.DB.Transaction(func(tx *gorm.DB) error {
+		// Someone may have signed up with it in the meantime
+		if taken, err := emailTaken(tx, pending.Email); err != nil {
+			return err
+		} else if taken {
+			return errEmailTaken
+		}
+		if err := tx.Model(&model.User{}).Where("user_id = ?", userID).Update("email", pending.Email).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.Profile{}).Where("user_id = ?", userID).Update("email", pending.Email).Error; err != nil {
+			return err
+		}
+		// Delete any pre-existing log for this user
+		// (as it is syncing data based on change_logs table)
+		if err := tx.Where("user_id = ?", userID).Delete(&model.ChangeLog{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&model.ChangeLog{UserID: userID, Action: model.Update}).Error
+	}); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.Is(err, errEmailTaken) || (errors.As(err, &pgErr) && pgErr.Code == "23505") {
+			c.JSON(http.StatusConflict, gin.H{"error": "Email already in use"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change email"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Email changed successfully", "email": pending.Email})
+}
+
+// deleted accounts keep their email, the unique index covers them as well
+func emailTaken(db *gorm.DB, email string) (bool, error) {
+	var count int64
+	if err := db.Unscoped().Model(&model.User{}).Where("email = ?", email).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"compass/connections"
+	"compass/model"
+	"compass/workers"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// Building the archive reads all the uploads of the user, once a day is plenty
+const exportInterval = 24 * time.Hour
+
+// Recent exports of the user, the frontend shows their status
+func listExportsHandler(c *gin.Context) {
+	var exports []model.DataExport
+	if err := connections.DB.
+		Where("user_id = ?", c.MustGet("userID").(uuid.UUID)).
+		Order("created_at DESC").
+		Find(&exports).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch exports"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"exports": exports})
+}
+
+// Queues the archive of the user's data, the link is mailed once it is built
+func requestExportHandler(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	var recent int64
+	if err := connections.DB.Model(&model.DataExport{}).
+		Where("user_id = ? AND status <> ? AND created_at > ?", userID, model.ExportFailed, time.Now().Add(-exportInterval)).
+		Count(&recent).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if recent > 0 {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "You can request one export a day, please use the link sent to your email"})
+		return
+	}
+
+	export := model.DataExport{UserID: userID, Status: model.ExportPending}
+	if err := connections.DB.Create(&export).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to request export"})
+		return
+	}
+	payload, _ := json.Marshal(workers.ExportJob{ExportID: export.ID})
+	if err := workers.PublishJob(payload, model.ExportQueue); err != nil {
+		logrus.Error("Failed to enqueue export job:", err)
+		connections.DB.Delete(&export)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to request export"})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "We are preparing your data, you will receive an email with the download link", "export": export})
+}
+
+// From the mailed link, the token is enough as the mail may be opened on any device
+func downloadExportHandler(c *gin.Context) {
+	exportID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid export id"})
+		return
+	}
+	attempts := []attempt{{otpIPLimiter, c.ClientIP()}}
+	if throttled(c, attempts...) {
+		return
+	}
+
+	var export model.DataExport
+	if err := connections.DB.First(&export, "id = ? AND status = ?", exportID, model.ExportReady).Error; err != nil ||
+		subtle.ConstantTimeCompare([]byte(export.TokenHash), []byte(workers.ExportTokenHash(c.Query("token")))) != 1 {
+		recordFailure(c, nil, attempts...)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invalid link"})
+		return
+	}
+	if export.ExpiresAt == nil || export.ExpiresAt.Before(time.Now()) {
+		c.JSON(http.StatusGone, gin.H{"error": "Link has expired, please request a new export"})
+		return
+	}
+
+	// The archive may take longer than the write timeout of the server
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Now().Add(10 * time.Minute)); err != nil {
+		logrus.Warn("Failed to extend the write deadline: ", err)
+	}
+	c.FileAttachment(workers.ExportPath(export.ID), "compass-data-"+export.CreatedAt.Format("2006-01-02")+".zip")
+}
@@ -1,47 +1,20 @@
 package auth
 
 import (
-	"bytes"
+	"compass/captcha"
 	"compass/connections"
 	"compass/middleware"
 	"compass/model"
-	"encoding/json"
-	"io"
+	"compass/password"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/spf13/viper"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
 
-// Helper Function: Verify Recaptcha calls Google API to validate token
-func verifyRecaptcha(token string) bool {
-	secret := viper.GetString("recaptcha.key")
-	url := "https://www.google.com/recaptcha/api/siteverify"
-
-	data := []byte("secret=" + secret + "&response=" + token)
-	resp, err := http.Post(url, "application/x-www-form-urlencoded", bytes.NewBuffer(data))
-	if err != nil {
-		return false
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-	var recaptchaResp RecaptchaResponse
-	if err := json.Unmarshal(body, &recaptchaResp); err != nil {
-		return false
-	}
-
-	// FOR DEV: You may keep it always true
-	// Only accept if successful and score >= 0.5
-	if recaptchaResp.Success && recaptchaResp.Score >= 0.5 {
-		return true
-	}
-	return false
-}
-
 func loginHandler(c *gin.Context) {
 	var req LoginSignupRequest
 	var dbUser model.User
@@ -51,21 +24,30 @@ func loginHandler(c *gin.Context) {
 		return
 	}
 
-	// FOR DEV: BYPASS RE-CAPTCHA
-	// ----------------------------------------------------------------------------- //
-	if viper.GetString("env") == "prod" {
-		if !verifyRecaptcha(req.Token) {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Failed captcha verification"})
-			return
-		}
+	// Use the fake captcha provider in dev, see captcha.provider in config
+	if err := captcha.Check(req.Token, captcha.ActionLogin, c.ClientIP()); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Failed captcha verification"})
+		return
+	}
+
+	// Failures are counted per account and per ip
+	email := strings.ToLower(req.Email)
+	attempts := []attempt{{loginEmailLimiter, email}, {loginIPLimiter, c.ClientIP()}}
+	if throttled(c, attempts...) {
+		return
 	}
-	// ----------------------------------------------------------------------------- //
 
 	//  Fetch user from DB
-	result := connections.DB.Model(&model.User{}).Select("email", "user_id", "password", "role", "is_verified").
-		Where("email = ?", strings.ToLower(req.Email)).First(&dbUser)
+	result := connections.DB.Model(&model.User{}).Select("email", "user_id", "password", "role", "is_verified", "totp_enabled", "suspended_at", "expires_at").
+		Where("email = ?", email).First(&dbUser)
 	if result.Error != nil {
 		if result.Error == gorm.ErrRecordNotFound {
+			// Counted as well, users should not know who is not on platform
+			eventFailure(c, model.EventLogin, nil, email, "unknown account")
+			if lockout := recordFailure(c, nil, attempts...); lockout > 0 {
+				tooManyAttempts(c, lockout)
+				return
+			}
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"}) // For protection, users should not know who is not on platform
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
@@ -74,30 +56,64 @@ func loginHandler(c *gin.Context) {
 	}
 
 	//  Checking password
-	if err := bcrypt.CompareHashAndPassword([]byte(dbUser.Password), []byte(req.Password)); err != nil {
+	match, needsRehash, err := password.Verify(req.Password, dbUser.Password)
+	if err != nil {
+		logrus.Errorf("Password hash of %s: %v", dbUser.UserID, err)
+	}
+	if !match {
+		eventFailure(c, model.EventLogin, &dbUser.UserID, email, "wrong password")
 		middleware.ClearAuthCookie(c)
+		if lockout := recordFailure(c, &dbUser.UserID, attempts...); lockout > 0 {
+			tooManyAttempts(c, lockout)
+			return
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
+	// Only the account is cleared, the ip is shared by many users
+	resetFailures(attempts[0])
+	// Older algorithm or lower work factor, the password is only known now
+	if needsRehash {
+		upgradePasswordHash(dbUser.UserID, dbUser.Password, req.Password)
+	}
 	// check if verified
 	if !dbUser.IsVerified {
+		eventFailure(c, model.EventLogin, &dbUser.UserID, email, "email not verified")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Email not verified"})
 		return
 	}
 
-	// Creating JWT token
-	accessToken, err := middleware.GenerateAccessToken(dbUser.UserID)
-	refreshToken, err := middleware.GenerateRefreshToken(dbUser.UserID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+	if dbUser.SuspendedAt != nil {
+		eventFailure(c, model.EventLogin, &dbUser.UserID, email, "suspended")
+		c.JSON(http.StatusForbidden, gin.H{"error": "Your account is suspended"})
+		return
+	}
+	if dbUser.ExpiresAt != nil && dbUser.ExpiresAt.Before(time.Now()) {
+		eventFailure(c, model.EventLogin, &dbUser.UserID, email, "visitor account expired")
+		c.JSON(http.StatusForbidden, gin.H{"error": "Your visitor account has expired"})
+		return
+	}
+
+	// Two factor enabled, cookies are issued only after the code is verified at /login/2fa
+	if dbUser.TOTPEnabled {
+		mfaToken, err := middleware.GenerateMFAToken(dbUser.UserID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+		middleware.ClearAuthCookie(c)
+		middleware.SetMFACookie(c, mfaToken)
+		eventSuccess(c, model.EventLogin, &dbUser.UserID, "second factor pending")
+		c.JSON(http.StatusOK, gin.H{"message": "Two factor authentication required", "mfaRequired": true})
 		return
 	}
 
-	// Clear the previous cookie
-	middleware.ClearAuthCookie(c)
-	// Set cookie
-	middleware.SetAuthCookie(c, accessToken)
-	middleware.SetRefreshCookie(c, refreshToken)
+	// Creating the session, sets both the cookies
+	if err := middleware.StartSession(c, dbUser.UserID, false); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	eventSuccess(c, model.EventLogin, &dbUser.UserID, "")
 
 	c.JSON(http.StatusOK, gin.H{"message": "Login successful"})
 }
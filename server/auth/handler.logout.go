@@ -8,6 +8,7 @@ import (
 )
 
 func logoutHandler(c *gin.Context) {
-	middleware.ClearAuthCookie(c)
+	// Revoke the session of this device and clear the cookies
+	middleware.EndSession(c)
 	c.JSON(http.StatusOK, gin.H{"message": "Logged Out Successfully"})
 }
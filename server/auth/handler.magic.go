@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"compass/captcha"
+	"compass/connections"
+	"compass/middleware"
+	"compass/model"
+	"compass/workers"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Short, the link logs in without the password
+const magicLinkExpiry = 10 * time.Minute
+
+const magicLinkMessage = "If this email is registered, you will receive a login link."
+
+// Passwordless login, mails a single use link which logs in on opening
+func magicLinkHandler(c *gin.Context) {
+	var req MagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	if err := captcha.Check(req.Token, captcha.ActionMagicLink, c.ClientIP()); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Failed captcha verification"})
+		return
+	}
+
+	// Every request sends a mail, so all of them count
+	email := strings.ToLower(req.Email)
+	attempts := []attempt{{magicEmailLimiter, email}, {magicIPLimiter, c.ClientIP()}}
+	if throttled(c, attempts...) {
+		return
+	}
+	recordFailure(c, nil, attempts...)
+
+	var user model.User
+	if err := connections.DB.Select("user_id", "email", "is_verified", "suspended_at", "expires_at").
+		Where("email = ?", email).First(&user).Error; err != nil {
+		// Do not reveal if email exists or not
+		c.JSON(http.StatusOK, gin.H{"message": magicLinkMessage})
+		return
+	}
+	// The login would be refused anyway, no need to send the mail
+	if !user.IsVerified || user.SuspendedAt != nil || (user.ExpiresAt != nil && user.ExpiresAt.Before(time.Now())) {
+		c.JSON(http.StatusOK, gin.H{"message": magicLinkMessage})
+		return
+	}
+
+	if err := sendMagicLinkMail(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send login link"})
+		return
+	}
+	eventSuccess(c, model.EventMagicLinkRequest, &user.UserID, "")
+	c.JSON(http.StatusOK, gin.H{"message": magicLinkMessage})
+}
+
+func sendMagicLinkMail(user model.User) error {
+	// Separate purpose, a pending signup otp or reset link is left as it is
+	token, err := generateLinkToken()
+	if err != nil {
+		return err
+	}
+	if err := issueToken(connections.DB, model.OneTimeToken{UserID: user.UserID, Purpose: model.PurposeMagicLogin}, token, magicLinkExpiry); err != nil {
+		return err
+	}
+
+	// Opened by the frontend page which posts it back, mail scanners following the link do not use it up
+	link := fmt.Sprintf("%s/login/magic?token=%s&id=%s", viper.GetString("frontend_url"), token, user.UserID.String())
+	job := workers.MailJob{
+		Type: "magic_link",
+		To:   user.Email,
+		Data: map[string]interface{}{
+			"link":    link,
+			"minutes": int(magicLinkExpiry.Minutes()),
+		},
+	}
+	payload, _ := json.Marshal(job)
+	if err := workers.PublishJob(payload, model.MailQueue); err != nil {
+		logrus.Error("Failed to enqueue mail job:", err)
+		return err
+	}
+	return nil
+}
+
+// Second half of the magic link, sets the same cookies as the password login
+func magicLoginHandler(c *gin.Context) {
+	var req MagicLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	userID, err := uuid.Parse(req.UserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	attempts := []attempt{{otpIPLimiter, c.ClientIP()}}
+	if throttled(c, attempts...) {
+		return
+	}
+
+	if _, err := consumeToken(userID, model.PurposeMagicLogin, req.Token); err != nil {
+		switch {
+		case errors.Is(err, errTokenExpired):
+			eventFailure(c, model.EventMagicLogin, &userID, "", "link expired")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Link has expired, please request a new one"})
+		case errors.Is(err, errTokenInvalid), errors.Is(err, errTokenExhausted):
+			eventFailure(c, model.EventMagicLogin, &userID, "", "invalid link")
+			recordFailure(c, &userID, attempts...)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid link"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log in"})
+		}
+		return
+	}
+
+	// State may have changed after the link was sent
+	var user model.User
+	if err := connections.DB.Select("user_id", "is_verified", "totp_enabled", "suspended_at", "expires_at").
+		First(&user, "user_id = ?", userID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid link"})
+		return
+	}
+	if !user.IsVerified {
+		eventFailure(c, model.EventMagicLogin, &user.UserID, "", "email not verified")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Email not verified"})
+		return
+	}
+	if user.SuspendedAt != nil {
+		eventFailure(c, model.EventMagicLogin, &user.UserID, "", "suspended")
+		c.JSON(http.StatusForbidden, gin.H{"error": "Your account is suspended"})
+		return
+	}
+	if user.ExpiresAt != nil && user.ExpiresAt.Before(time.Now()) {
+		eventFailure(c, model.EventMagicLogin, &user.UserID, "", "visitor account expired")
+		c.JSON(http.StatusForbidden, gin.H{"error": "Your visitor account has expired"})
+		return
+	}
+
+	// The link replaces the password only, the second factor is still asked at /login/2fa
+	if user.TOTPEnabled {
+		mfaToken, err := middleware.GenerateMFAToken(user.UserID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+		middleware.ClearAuthCookie(c)
+		middleware.SetMFACookie(c, mfaToken)
+		eventSuccess(c, model.EventMagicLogin, &user.UserID, "second factor pending")
+		c.JSON(http.StatusOK, gin.H{"message": "Two factor authentication required", "mfaRequired": true})
+		return
+	}
+
+	if err := middleware.StartSession(c, user.UserID, false); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	eventSuccess(c, model.EventMagicLogin, &user.UserID, "")
+	c.JSON(http.StatusOK, gin.H{"message": "Login successful"})
+}
@@ -2,21 +2,16 @@ package auth
 
 import (
 	"compass/connections"
+	"compass/directory"
 	"compass/middleware"
 	"compass/model"
 	"errors"
-	"net/http"
-	"net/url"
-	"strings"
-
-	"encoding/json"
 	"fmt"
-	"time"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
-	"github.com/spf13/viper"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 	"gorm.io/gorm"
@@ -24,62 +19,21 @@ import (
 
 var caser = cases.Title(language.English)
 
-func verifyProfile(c *gin.Context, profileData model.Profile) bool {
-	// OA's verification route, do not take name input, but returns name upon verification
-	// We request with the email and the user data provided, OA verifies and returns true and false,
-	// If the verification is successful, we receive the name of the user.
-	paramkey := fmt.Sprintf("%s:%s:%s:%s", profileData.RollNo, profileData.Course, profileData.Dept, profileData.Email)
-	paramkey = url.QueryEscape(paramkey) // To ensure the paramkey for "(MSc 2yr)" and such cases is correctly parsed
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s?paramkey=%s", viper.GetString("oa.url"), paramkey), nil)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create request"})
-		return false
-	}
-	req.Header.Set("x-api-key", viper.GetString("oa.key"))
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to call OA API"})
-		return false
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == 401 {
-		// Log the critical error
-		// Send mail to maintainers
-		logrus.Errorf("OA Token expired or missing, Urgent action required, request new or check viper env")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Programming club's oa token expired, we are working to resolve it as soon as possible"})
-		return false
-	} else if resp.StatusCode != 200 {
-		logrus.Error("OA API ERROR, with status code: ", resp.StatusCode)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Details"})
-		return false
-	}
-	// Parse the response
-	var apiResp CCResponse
-	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse OA API response"})
-		return false
-	}
-
-	// Checking Status of verification
-	if apiResp.Status != nil {
-		// Normalize names by removing extra whitespaces and comparing case-insensitively
-		normalizedInputName := strings.ToLower(strings.Join(strings.Fields(profileData.Name), " "))
-		normalizedApiRespName := strings.ToLower(strings.Join(strings.Fields(*apiResp.Name), " "))
-		if *apiResp.Status != "true" || normalizedInputName != normalizedApiRespName {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "Please verify your data. It should be exactly same as: 1. on your ID card, or 2. displayed in IITK APP or 3. Initial Branch, if Branch is changed.",
-			})
-			return false
-		}
-	} else {
+// Maps the errors of the student directory to the responses
+func directoryError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, directory.ErrMismatch):
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Student data not verified",
+			"error": "Please verify your data. It should be exactly same as: 1. on your ID card, or 2. displayed in IITK APP or 3. Initial Branch, if Branch is changed.",
 		})
-		return false
+	case errors.Is(err, directory.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Student data not found"})
+	case errors.Is(err, directory.ErrUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Student records are unavailable right now, we are working to resolve it as soon as possible"})
+	default:
+		logrus.WithError(err).Error("Student directory error")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify student details"})
 	}
-	return true
 }
 
 // Returns: (baccha, bapu, error)
@@ -181,8 +135,15 @@ func updateProfile(c *gin.Context) {
 		user.Profile.RollNo != profileData.RollNo ||
 		user.Profile.Dept != profileData.Dept ||
 		user.Profile.Course != profileData.Course {
-		// Verify from oa
-		if !verifyProfile(c, profileData) {
+		// Verify from the records (OA in prod)
+		if err := directory.Default().Verify(directory.Claim{
+			Email:  profileData.Email,
+			RollNo: profileData.RollNo,
+			Name:   profileData.Name,
+			Dept:   profileData.Dept,
+			Course: profileData.Course,
+		}); err != nil {
+			directoryError(c, err)
 			return
 		}
 
@@ -299,49 +260,9 @@ func autoC(c *gin.Context) {
 		return
 	}
 
-	automationServerURL := viper.GetString("automation.url")
-	if automationServerURL == "" {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Auth server configuration missing"})
-		return
-	}
-	client := &http.Client{Timeout: 10 * time.Second}
-	reqURL := fmt.Sprintf("%s/getDetails?email=%s", automationServerURL, user.Email)
-
-	req, err := http.NewRequest("GET", reqURL, nil)
+	studentDetails, err := directory.Default().Lookup(user.Email)
 	if err != nil {
-		logrus.WithError(err).Error("Failed to create automation request")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create request"})
-		return
-	}
-
-	req.Header.Set("x-api-key", viper.GetString("automation.key"))
-	req.Header.Set("Accept", "application/json")
-
-	resp, err := client.Do(req)
-	if err != nil {
-		logrus.WithError(err).Error("Failed to call automation server")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to call automation server"})
-		return
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		switch resp.StatusCode {
-		case http.StatusUnauthorized:
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication failed"})
-		case http.StatusNotFound:
-			c.JSON(http.StatusNotFound, gin.H{"error": "Not Found."})
-		default:
-			logrus.WithField("status", resp.StatusCode).Error("Automation server returned error")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Automation Server returned error"})
-		}
-		return
-	}
-
-	var studentDetails StudentDetails
-	if err := json.NewDecoder(resp.Body).Decode(&studentDetails); err != nil {
-		logrus.WithError(err).Error("Failed to parse auth server response")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse student details"})
+		directoryError(c, err)
 		return
 	}
 
@@ -0,0 +1,75 @@
+//go:build integration
+
+// Needs the postgres and rabbitmq of docker-compose: go test -tags integration ./auth
+package auth
+
+import (
+	"compass/connections"
+	"compass/directory"
+	"compass/model"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+)
+
+func TestUpdateProfileDirectoryErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	viper.Set("directory.verify", "fake")
+	viper.Set("directory.lookup", "fake")
+	if err := directory.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	user := model.User{Email: "profile-" + uuid.NewString()[:8] + "@iitk.ac.in", IsVerified: true, Role: model.UserRole}
+	if err := connections.DB.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	t.Cleanup(func() {
+		connections.DB.Unscoped().Where("user_id = ?", user.UserID).Delete(&model.Profile{})
+		connections.DB.Unscoped().Where("user_id = ?", user.UserID).Delete(&model.User{})
+	})
+
+	r := gin.New()
+	r.POST("/profile", func(c *gin.Context) { c.Set("userID", user.UserID) }, updateProfile)
+
+	// The fake directory fails on these values, see directory.Fake
+	tests := []struct {
+		name   string
+		rollNo string
+		person string
+		want   int
+	}{
+		{"mismatch", "230001", "Mismatch", http.StatusBadRequest},
+		{"not found", "404", "Test Student", http.StatusNotFound},
+		{"unavailable", "503", "Test Student", http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		body := fmt.Sprintf(`{"name":%q,"rollNo":%q,"dept":"CSE","course":"BT","gender":"M"}`, tt.person, tt.rollNo)
+		req := httptest.NewRequest(http.MethodPost, "/profile", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		var resp struct {
+			Error string `json:"error"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if w.Code != tt.want || resp.Error == "" {
+			t.Errorf("%s: %d %q, want %d with an error", tt.name, w.Code, resp.Error, tt.want)
+		}
+	}
+
+	// Nothing was saved
+	var profiles int64
+	connections.DB.Model(&model.Profile{}).Where("user_id = ? AND roll_no <> ''", user.UserID).Count(&profiles)
+	if profiles != 0 {
+		t.Errorf("%d profiles saved after the failed verifications, want 0", profiles)
+	}
+}
@@ -3,10 +3,14 @@ package auth
 import (
 	"fmt"
 	"strings"
+	"compass/captcha"
 	"compass/workers"
 	"compass/model"
 	"compass/connections"
+	"compass/middleware"
+	"compass/password"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"time"
 
@@ -14,7 +18,6 @@ import (
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
-	"golang.org/x/crypto/bcrypt"
 )
 
 func forgotPasswordHandler(c *gin.Context) {
@@ -23,9 +26,21 @@ func forgotPasswordHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := captcha.Check(req.Token, captcha.ActionForgotPassword, c.ClientIP()); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Failed captcha verification"})
+		return
+	}
+
+	// Every request sends a mail, so all of them count
+	email := strings.ToLower(req.Email)
+	attempts := []attempt{{forgotEmailLimiter, email}, {forgotIPLimiter, c.ClientIP()}}
+	if throttled(c, attempts...) {
+		return
+	}
+	recordFailure(c, nil, attempts...)
 
 	var user model.User
-	if err := connections.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
+	if err := connections.DB.Where("email = ?", email).First(&user).Error; err != nil {
 		// Do not reveal if email exists or not for security
 		c.JSON(http.StatusOK, gin.H{"message": "If this email is registered, you will receive a reset link."})
 		return
@@ -37,23 +52,32 @@ func forgotPasswordHandler(c *gin.Context) {
 		return
 	}
 
-	// Generate reset token
-	token := uuid.New().String()
-	expiry := time.Now().Add(15 * time.Minute)
-
-	// Format: token<>expiryTime
-	user.VerificationToken = fmt.Sprintf("%s<>%s", token, expiry.Format(time.RFC3339))
-	
-	if err := connections.DB.Save(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process request"})
+	if err := sendPasswordResetMail(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send reset email"})
 		return
 	}
+	eventSuccess(c, model.EventPasswordResetRequest, &user.UserID, "")
+
+	c.JSON(http.StatusOK, gin.H{"message": "If this email is registered, you will receive a reset link."})
+}
+
+// sendPasswordResetMail generates a reset token for the user and queues the mail with the link.
+// Also used by admins to force a password reset.
+func sendPasswordResetMail(user model.User) error {
+	// Generate reset token, only the hash is stored
+	token, err := generateLinkToken()
+	if err != nil {
+		return err
+	}
+	if err := issueToken(connections.DB, model.OneTimeToken{UserID: user.UserID, Purpose: model.PurposeResetPassword}, token, 15*time.Minute); err != nil {
+		return err
+	}
 
 	// Send email
 	// Replaced with actual frontend URL from env or similar, for now hardcoded matches previous logic
 	// Added id query param for identification
 	resetLink := fmt.Sprintf("%s/reset-password?token=%s&id=%s", viper.GetString("frontend_url"), token, user.UserID.String())
-	
+
 	job := workers.MailJob{
 		Type: "password_reset",
 		To:   user.Email,
@@ -62,16 +86,14 @@ func forgotPasswordHandler(c *gin.Context) {
 			"link":  resetLink,
 		},
 	}
-	
+
 	payload, _ := json.Marshal(job)
 	if err := workers.PublishJob(payload, model.MailQueue); err != nil {
 		// Log but continue
 		logrus.Error("Failed to enqueue mail job:", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send reset email"})
-		return
+		return err
 	}
-	
-	c.JSON(http.StatusOK, gin.H{"message": "If this email is registered, you will receive a reset link."})
+	return nil
 }
 
 func resetPasswordHandler(c *gin.Context) {
@@ -81,54 +103,64 @@ func resetPasswordHandler(c *gin.Context) {
 		return
 	}
 
-	var user model.User
-	// Find user by ID
-	if err := connections.DB.Where("user_id = ?", req.UserID).First(&user).Error; err != nil {
+	userID, err := uuid.Parse(req.UserID)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "User not found"})
 		return
 	}
-
-	// Verify token format "token<>expiry"
-	tokenSplit := strings.Split(user.VerificationToken, "<>")
-	if len(tokenSplit) != 2 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token format or no token pending"})
+	attempts := []attempt{{otpIPLimiter, c.ClientIP()}}
+	if throttled(c, attempts...) {
 		return
 	}
 
-	// Verify expiry
-	expiryTime, err := time.Parse(time.RFC3339, tokenSplit[1])
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token time"})
+	// Checked before the token is used up, so a rejected password can be retried with the same link
+	var user model.User
+	if err := connections.DB.Select("user_id", "email").Preload("Profile").First(&user, "user_id = ?", userID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reset token"})
 		return
 	}
-
-	if time.Now().After(expiryTime) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Reset token has expired"})
+	if weakPassword(c, req.Password, password.User{Email: user.Email, RollNo: user.Profile.RollNo}) {
 		return
 	}
 
-	// Verify token content
-	if tokenSplit[0] != req.Token {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reset token"})
+	if _, err := consumeToken(userID, model.PurposeResetPassword, req.Token); err != nil {
+		switch {
+		case errors.Is(err, errTokenExpired):
+			eventFailure(c, model.EventPasswordReset, &userID, "", "link expired")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Reset token has expired"})
+		case errors.Is(err, errTokenInvalid), errors.Is(err, errTokenExhausted):
+			eventFailure(c, model.EventPasswordReset, &userID, "", "invalid link")
+			recordFailure(c, &userID, attempts...)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reset token"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
+		}
 		return
 	}
 
 	// Hash new password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hashedPassword, err := password.Hash(req.Password)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process password"})
 		return
 	}
 
 	// Update user
-	user.Password = string(hashedPassword)
-	user.VerificationToken = "" // Clear token
-	// user.IsVerified = true      // Auto-verify user on password reset success -> REMOVED FOR SECURITY
-
-	if err := connections.DB.Save(&user).Error; err != nil {
+	// Auto-verify user on password reset success -> REMOVED FOR SECURITY
+	if err := connections.DB.Model(&model.User{}).Where("user_id = ?", userID).
+		Update("password", hashedPassword).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
 		return
 	}
 
+	// Whoever knew the old password gets logged out
+	if err := middleware.RevokeAllSessions(userID); err != nil {
+		logrus.Errorf("Failed to revoke the sessions of %s after password reset: %v", userID, err)
+	}
+	if err := middleware.RevokePersonalTokens(userID); err != nil {
+		logrus.Errorf("Failed to revoke the access tokens of %s after password reset: %v", userID, err)
+	}
+
+	eventSuccess(c, model.EventPasswordReset, &userID, "")
 	c.JSON(http.StatusOK, gin.H{"message": "Password updated successfully"})
 }
@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"compass/connections"
+	"compass/middleware"
+	"compass/model"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Roles along with the permissions they grant
+func listRolesHandler(c *gin.Context) {
+	var roles []model.AccessRole
+	if err := connections.DB.Preload("Permissions").Order("name").Find(&roles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch roles"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"roles": roles})
+}
+
+func listPermissionsHandler(c *gin.Context) {
+	var permissions []model.Permission
+	if err := connections.DB.Order("name").Find(&permissions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch permissions"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"permissions": permissions})
+}
+
+// Roles assigned to a user, along with the base role and the resulting permissions
+func userRolesHandler(c *gin.Context) {
+	targetID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	var user model.User
+	if err := connections.DB.Select("user_id", "email", "role").First(&user, "user_id = ?", targetID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	var assignments []model.RoleAssignment
+	if err := connections.DB.Where("user_id = ?", targetID).Find(&assignments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch roles"})
+		return
+	}
+	permissions, err := middleware.UserPermissions(user.UserID, user.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch permissions"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"email":       user.Email,
+		"baseRole":    user.Role.BaseRoleName(),
+		"roles":       assignments,
+		"permissions": permissions,
+	})
+}
+
+func assignRoleHandler(c *gin.Context) {
+	var req RoleAssignmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	targetID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	actorID, _ := c.Get("userID")
+
+	if err := connections.DB.First(&model.User{}, "user_id = ?", targetID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err := connections.DB.First(&model.AccessRole{}, "name = ?", req.Role).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return
+	}
+	if err := connections.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.RoleAssignment{
+		UserID:     targetID,
+		RoleName:   req.Role,
+		AssignedBy: actorID.(uuid.UUID),
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign role"})
+		return
+	}
+	middleware.InvalidatePermissions(targetID)
+	c.JSON(http.StatusOK, gin.H{"message": "Role assigned"})
+}
+
+func unassignRoleHandler(c *gin.Context) {
+	targetID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	role := c.Param("role")
+
+	if err := connections.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("user_id = ? AND role_name = ?", targetID, role).Delete(&model.RoleAssignment{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		// Never leave the system without someone who can assign roles
+		if role == model.RoleNameSuperAdmin {
+			var remaining int64
+			if err := tx.Model(&model.RoleAssignment{}).Where("role_name = ?", model.RoleNameSuperAdmin).Count(&remaining).Error; err != nil {
+				return err
+			}
+			if remaining == 0 {
+				return errLastSuperAdmin
+			}
+		}
+		return nil
+	}); err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Role is not assigned to the user"})
+		case errors.Is(err, errLastSuperAdmin):
+			c.JSON(http.StatusConflict, gin.H{"error": "Can not remove the last super admin"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove role"})
+		}
+		return
+	}
+	middleware.InvalidatePermissions(targetID)
+	c.JSON(http.StatusOK, gin.H{"message": "Role removed"})
+}
+
+var errLastSuperAdmin = errors.New("last super admin")
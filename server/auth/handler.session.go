@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"compass/connections"
+	"compass/middleware"
+	"compass/model"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// List the active sessions (devices) of the user
+func listSessionsHandler(c *gin.Context) {
+	userID, exist := c.Get("userID")
+	if !exist {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	currentSession, _ := c.Get("sessionID")
+
+	var sessions []model.Session
+	if err := connections.DB.
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID.(uuid.UUID), time.Now()).
+		Order("last_used_at DESC").
+		Find(&sessions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+		return
+	}
+
+	type sessionResp struct {
+		model.Session
+		Current bool `json:"current"`
+	}
+	resp := make([]sessionResp, 0, len(sessions))
+	for _, s := range sessions {
+		resp = append(resp, sessionResp{Session: s, Current: s.SessionID == currentSession})
+	}
+	c.JSON(http.StatusOK, gin.H{"sessions": resp})
+}
+
+// Log out a single device
+func revokeSessionHandler(c *gin.Context) {
+	userID, exist := c.Get("userID")
+	if !exist {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+	if err := middleware.RevokeSession(userID.(uuid.UUID), sessionID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+	// Revoking the current device is a plain logout
+	if currentSession, _ := c.Get("sessionID"); currentSession == sessionID {
+		middleware.ClearAuthCookie(c)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+// Log out from all the devices, including this one
+func revokeAllSessionsHandler(c *gin.Context) {
+	userID, exist := c.Get("userID")
+	if !exist {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	if err := middleware.RevokeAllSessions(userID.(uuid.UUID)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out from all devices"})
+		return
+	}
+	middleware.ClearAuthCookie(c)
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out from all devices"})
+}
@@ -1,8 +1,10 @@
 package auth
 
 import (
+	"compass/captcha"
 	"compass/connections"
 	"compass/model"
+	"compass/password"
 	"compass/workers"
 	"encoding/json"
 	"errors"
@@ -12,10 +14,10 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
@@ -26,47 +28,66 @@ func signupHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
 		return
 	}
-	//Allow only IITK emails
-	if !strings.HasSuffix(strings.ToLower(input.Email), "@iitk.ac.in") {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Please use a valid IIT Kanpur email address"})
+	// IITK emails become users, the rest may join as visitors through the allowed domains or an invite code
+	email := strings.ToLower(input.Email)
+	visitor := !strings.HasSuffix(email, "@iitk.ac.in")
+	if visitor && !visitorDomainAllowed(email) && input.InviteCode == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Please use a valid IIT Kanpur email address, or an invite code"})
 		return
 	}
 
-	// FOR DEV: BYPASS RECAPTCHA
-	// ----------------------------------------------------------------------------- //
 	// Throws error if captcha verification fails
 	// registers the user in the DB only when the captcha is passed
+	if err := captcha.Check(input.Token, captcha.ActionSignup, c.ClientIP()); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Failed captcha verification"})
+		return
+	}
 
-	if viper.GetString("env") == "prod" {
-		if !verifyRecaptcha(input.Token) {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Failed captcha verification"})
-			return
-		}
+	// Roll number is not known yet, it comes with the profile
+	if weakPassword(c, input.Password, password.User{Email: email}) {
+		return
 	}
-	// ----------------------------------------------------------------------------- //
 
 	// TODO: extract out the user model generation into a single transaction
 	// Generate token and the user
-	hashPass, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	hashPass, err := password.Hash(input.Password)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating user"})
 		return
 	}
 
 	//  Generating verification token
-	token := generateVerificationToken()
-	expiry := time.Now().Add(time.Duration(viper.GetInt("expiry.emailVerification")) * time.Hour).Format(time.RFC3339)
+	token, err := generateVerificationToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating user"})
+		return
+	}
 	user := model.User{
-		Email:             strings.ToLower(input.Email),
-		Password:          string(hashPass),
-		IsVerified:        false,
-		Role:              model.UserRole,
-		VerificationToken: fmt.Sprintf("%s<>%s", token, expiry),
-		Profile:           model.Profile{Email: strings.ToLower(input.Email), Visibility: true},
+		Email:      email,
+		Password:   hashPass,
+		IsVerified: false,
+		Role:       model.UserRole,
+		Profile:    model.Profile{Email: email, Visibility: true},
 	}
 
 	// Saving user in DB and updating in changelog
 	if err := connections.DB.Transaction(func(tx *gorm.DB) error {
+		if visitor {
+			days := visitorAccountDays()
+			// The allowed domains do not need the code, an invite given anyway is not used up
+			if !visitorDomainAllowed(email) {
+				var err error
+				if days, err = redeemInvite(tx, input.InviteCode); err != nil {
+					return err
+				}
+			}
+			expiresAt := time.Now().AddDate(0, 0, days)
+			user.Role = model.VisitorRole
+			user.ExpiresAt = &expiresAt
+			// Visitors are not students, never listed in the student search
+			user.Profile.Visibility = false
+		}
+
 		// Create the User (and Profile via nested struct)
 		if err := tx.Create(&user).Error; err != nil {
 			return err // This error bubbles up to the if err != nil check below
@@ -82,6 +103,11 @@ func signupHandler(c *gin.Context) {
 			return err
 		}
 
+		// Only the hash of the otp is stored
+		if err := issueToken(tx, model.OneTimeToken{UserID: user.UserID, Purpose: model.PurposeVerifyEmail}, token, emailVerificationExpiry()); err != nil {
+			return err
+		}
+
 		return nil
 	}); err != nil {
 		// Handle Duplicate User Error (Postgres Code 23505)
@@ -90,12 +116,29 @@ func signupHandler(c *gin.Context) {
 			c.JSON(http.StatusConflict, gin.H{"error": "User already exists"})
 			return
 		}
+		if errors.Is(err, errInviteInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired invite code"})
+			return
+		}
 		// Handle other DB errors
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating user"})
 		return
 	}
 
 	//  Add mail job to queue
+	if err := sendVerificationMail(user.Email, user.UserID, token); err != nil {
+		// Log but continue
+		logrus.Error("Failed to enqueue mail job:", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Signup successful. Please check your email to verify.",
+		"userID":  user.UserID,
+	})
+}
+
+// sendVerificationMail queues the mail with the otp and the direct verification link
+func sendVerificationMail(email string, userID uuid.UUID, token string) error {
 	verifyLink := fmt.Sprintf("%s/signup?token=%s&userID=%s",
 		// Dev Mode, call the anonymous function
 		func() string {
@@ -105,11 +148,11 @@ func signupHandler(c *gin.Context) {
 			return fmt.Sprintf("https://%s.%s", "auth", viper.GetString("domain"))
 		}(),
 		token,
-		user.UserID)
+		userID)
 
 	job := workers.MailJob{
 		Type: "user_verification",
-		To:   input.Email,
+		To:   email,
 		Data: map[string]interface{}{
 			// To match the format in the UI, kB1-2Cd etc.
 			"token": fmt.Sprintf("%s-%s", token[:3], token[3:]),
@@ -117,13 +160,5 @@ func signupHandler(c *gin.Context) {
 		},
 	}
 	payload, _ := json.Marshal(job)
-	if err := workers.PublishJob(payload, model.MailQueue); err != nil {
-		// Log but continue
-		logrus.Error("Failed to enqueue mail job:", err)
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Signup successful. Please check your email to verify.",
-		"userID":  user.UserID,
-	})
+	return workers.PublishJob(payload, model.MailQueue)
 }
@@ -0,0 +1,261 @@
+package auth
+
+import (
+	"compass/connections"
+	"compass/middleware"
+	"compass/model"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Step 1 of enrollment: generate a secret, it is enabled only after the user confirms a code
+func setupTwoFactorHandler(c *gin.Context) {
+	userID, exist := c.Get("userID")
+	if !exist {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	var user model.User
+	if err := connections.DB.Select("user_id", "email", "totp_enabled").
+		First(&user, "user_id = ?", userID.(uuid.UUID)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two factor authentication is already enabled"})
+		return
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+	if err := connections.DB.Model(&model.User{}).Where("user_id = ?", user.UserID).
+		Updates(map[string]interface{}{"totp_secret": secret, "totp_last_counter": 0}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save secret"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"secret": secret,
+		"uri":    totpURI(secret, user.Email),
+	})
+}
+
+// Step 2 of enrollment: a valid code enables two factor and returns the recovery codes (shown only once)
+func confirmTwoFactorHandler(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	userID, exist := c.Get("userID")
+	if !exist {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	var user model.User
+	if err := connections.DB.Select("user_id", "totp_secret", "totp_enabled", "totp_last_counter").
+		First(&user, "user_id = ?", userID.(uuid.UUID)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two factor authentication is already enabled"})
+		return
+	}
+	if user.TOTPSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Start the two factor setup first"})
+		return
+	}
+	step, ok := validateTOTP(user.TOTPSecret, req.Code, user.TOTPLastCounter)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
+		return
+	}
+
+	var codes []string
+	if err := connections.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.User{}).Where("user_id = ?", user.UserID).
+			Updates(map[string]interface{}{"totp_enabled": true, "totp_last_counter": step}).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.UserID)
+		return err
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two factor authentication"})
+		return
+	}
+
+	// The user just proved the second factor, no need to login again on this device
+	sessionID, _ := c.Get("sessionID")
+	if id, ok := sessionID.(uuid.UUID); ok {
+		if err := middleware.MarkSessionMFAVerified(c, user.UserID, id); err != nil {
+			c.JSON(http.StatusOK, gin.H{"message": "Two factor authentication enabled, please login again", "recoveryCodes": codes})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Two factor authentication enabled", "recoveryCodes": codes})
+}
+
+// Turn two factor off, needs a valid code. Admins (any privileged permission) are not allowed to turn it off.
+func disableTwoFactorHandler(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	userID, exist := c.Get("userID")
+	if !exist {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	var user model.User
+	if err := connections.DB.Select("user_id", "role", "totp_secret", "totp_enabled", "totp_last_counter").
+		First(&user, "user_id = ?", userID.(uuid.UUID)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	// Anyone holding a privileged permission (admins, coordinators...) must keep it on
+	permissions, err := middleware.UserPermissions(user.UserID, user.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify permissions"})
+		return
+	}
+	for permission := range permissions {
+		if model.IsPrivileged(permission) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Two factor authentication is mandatory for admins"})
+			return
+		}
+	}
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two factor authentication is not enabled"})
+		return
+	}
+	if ok, err := verifySecondFactor(user, req.Code); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return
+	} else if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
+		return
+	}
+
+	if err := connections.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.User{}).Where("user_id = ?", user.UserID).
+			Updates(map[string]interface{}{"totp_enabled": false, "totp_secret": "", "totp_last_counter": 0}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.UserID).Delete(&model.RecoveryCode{}).Error
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two factor authentication"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Two factor authentication disabled"})
+}
+
+// Replace the recovery codes, the old ones stop working
+func regenerateRecoveryCodesHandler(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	userID, exist := c.Get("userID")
+	if !exist {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	var user model.User
+	if err := connections.DB.Select("user_id", "totp_secret", "totp_enabled", "totp_last_counter").
+		First(&user, "user_id = ?", userID.(uuid.UUID)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two factor authentication is not enabled"})
+		return
+	}
+	// Only a TOTP code here, using a recovery code to get new ones makes no sense
+	step, ok := validateTOTP(user.TOTPSecret, req.Code, user.TOTPLastCounter)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
+		return
+	}
+
+	var codes []string
+	if err := connections.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.User{}).
+			Where("user_id = ? AND totp_last_counter < ?", user.UserID, step).
+			Update("totp_last_counter", step)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.UserID)
+		return err
+	}); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recoveryCodes": codes})
+}
+
+// Second step of the login, cookies are issued only once the code is verified
+func loginSecondFactorHandler(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	userID, err := middleware.ParseMFAToken(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login session expired, please login again"})
+		return
+	}
+	var user model.User
+	if err := connections.DB.Select("user_id", "totp_secret", "totp_enabled", "totp_last_counter").
+		First(&user, "user_id = ?", userID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return
+	}
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two factor authentication is not enabled"})
+		return
+	}
+	attempts := []attempt{{otpUserLimiter, user.UserID.String()}, {otpIPLimiter, c.ClientIP()}}
+	if throttled(c, attempts...) {
+		return
+	}
+	if ok, err := verifySecondFactor(user, req.Code); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return
+	} else if !ok {
+		eventFailure(c, model.EventLoginSecondFactor, &user.UserID, "", "wrong code")
+		if lockout := recordFailure(c, &user.UserID, attempts...); lockout > 0 {
+			tooManyAttempts(c, lockout)
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
+		return
+	}
+	resetFailures(attempts[0])
+
+	middleware.ClearMFACookie(c)
+	if err := middleware.StartSession(c, user.UserID, true); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	eventSuccess(c, model.EventLoginSecondFactor, &user.UserID, "")
+	c.JSON(http.StatusOK, gin.H{"message": "Login successful"})
+}
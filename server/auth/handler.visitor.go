@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"compass/connections"
+	"compass/middleware"
+	"compass/model"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Visitor invites, for the admins. A code is shown only once, at creation
+
+func listInvitesHandler(c *gin.Context) {
+	var invites []model.VisitorInvite
+	if err := connections.DB.Order("created_at DESC").Find(&invites).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invites"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"invites": invites})
+}
+
+func createInviteHandler(c *gin.Context) {
+	var req CreateInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	code, err := generateLinkToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invite"})
+		return
+	}
+	invite := model.VisitorInvite{
+		CodeHash:    hashOneTimeToken(code),
+		Note:        req.Note,
+		MaxUses:     req.MaxUses,
+		AccountDays: req.AccountDays,
+		ExpiresAt:   time.Now().AddDate(0, 0, req.ValidDays),
+		CreatedBy:   c.MustGet("userID").(uuid.UUID),
+	}
+	if err := connections.DB.Create(&invite).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invite"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"invite": invite, "code": code})
+}
+
+// Accounts already made with the code are not affected
+func revokeInviteHandler(c *gin.Context) {
+	inviteID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invite ID"})
+		return
+	}
+	result := connections.DB.Model(&model.VisitorInvite{}).
+		Where("id = ? AND revoked_at IS NULL", inviteID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke invite"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Invite revoked"})
+}
+
+// Extend (or cut short) the life of a visitor account
+func visitorExpiryHandler(c *gin.Context) {
+	var req VisitorExpiryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	targetID, ok := adminTarget(c)
+	if !ok {
+		return
+	}
+	if err := connections.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.User{}).
+			Where("user_id = ? AND role = ?", targetID, model.VisitorRole).
+			Update("expires_at", req.ExpiresAt)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return connections.AddLog(tx, adminLog(c, targetID, "Visitor expiry changed", fmt.Sprintf("Account expires at %s", req.ExpiresAt.Format(time.RFC3339))))
+	}); err != nil {
+		adminActionError(c, err)
+		return
+	}
+	middleware.InvalidateAccount(targetID)
+	c.JSON(http.StatusOK, gin.H{"message": "Visitor expiry updated"})
+}
@@ -1,26 +1,20 @@
 package auth
 
+import "time"
+
 type LoginSignupRequest struct {
 	Email    string `form:"email" binding:"required,email"`
-	Password string `form:"password" binding:"required,min=8"`
-	// FOR DEV: BYPASS
+	Password string `form:"password" binding:"required"` // length is up to the password policy
+	// Captcha token, use the fake provider in dev
 	Token string `json:"token" binding:"required"`
+	// Signup only, lets an email outside the iitk and visitors.domains join as a visitor
+	InviteCode string `json:"inviteCode"`
 }
 
 type UpdatePasswordRequest struct {
 	NewPassword string `json:"password"`
 }
 
-type RecaptchaResponse struct {
-	// Output json struct from of https://www.google.com/recaptcha/api/siteverify
-	Success     bool     `json:"success"`      // whether this request was a valid reCAPTCHA token for your site
-	Score       float64  `json:"score"`        // the score for this request (0.0 - 1.0)
-	Action      string   `json:"action"`       // the action name for this request (important to verify)
-	ChallengeTS string   `json:"challenge_ts"` // timestamp of the challenge load (ISO format yyyy-MM-dd'T'HH:mm:ssZZ)
-	Hostname    string   `json:"hostname"`     // the hostname of the site where the reCAPTCHA was solved
-	ErrorCodes  []string `json:"error-codes"`  // optional
-}
-
 type ProfileUpdateRequest struct {
 	Name       string `json:"name"`
 	RollNo     string `json:"rollNo"`
@@ -32,34 +26,72 @@ type ProfileUpdateRequest struct {
 	HomeTown   *string `json:"homeTown"`
 }
 
-type CCResponse struct {
-	RollNumber *string `json:"rollNumber"`
-	Name       *string `json:"name"`
-	Email      *string `json:"email"`
-	Status     *string `json:"status"`
-	Timestamp  *string `json:"timestamp"`
-	Message    *string `json:"message"`
+type ForgotPasswordRequest struct {
+	Email string `form:"email" binding:"required,email"`
+	Token string `json:"token" binding:"required"`
 }
 
-type StudentDetails struct {
-	RollNo     string `json:"roll_no"`
-	Name       string `json:"name"`
-	Program    string `json:"program"`
-	Department string `json:"department"`
-	Gender     string `json:"gender"`
-	HostelInfo string `json:"hostel_info"`
-	Username   string `json:"username"`
-	Location   string `json:"location"`
+type MagicLinkRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Token string `json:"token" binding:"required"`
 }
 
-
-type ForgotPasswordRequest struct {
-	Email string `form:"email" binding:"required,email"`
-	Token string `json:"token" binding:"required"`
+// From the magic link in the mail
+type MagicLoginRequest struct {
+	UserID string `json:"id" binding:"required,uuid"`
+	Token  string `json:"token" binding:"required"`
 }
 
 type ResetPasswordRequest struct {
 	UserID   string `json:"id" binding:"required,uuid"`
 	Token    string `json:"token" binding:"required"`
-	Password string `json:"password" binding:"required,min=8"`
-}
\ No newline at end of file
+	Password string `json:"password" binding:"required"` // checked against the password policy
+}
+
+// TOTP code from the authenticator app, or a recovery code where allowed
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type RoleAssignmentRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+type ChangeUserRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=visitor user admin"`
+}
+
+type SuspendUserRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ChangeEmailRequest struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
+
+type ConfirmEmailChangeRequest struct {
+	UserID string `json:"id" binding:"required,uuid"`
+	Token  string `json:"token" binding:"required"`
+}
+
+type CreateAccessTokenRequest struct {
+	Name          string   `json:"name" binding:"required,max=64"`
+	Permissions   []string `json:"permissions" binding:"required,min=1"`
+	ExpiresInDays int      `json:"expiresInDays" binding:"required,min=1,max=365"`
+}
+
+type CreateInviteRequest struct {
+	Note        string `json:"note" binding:"required"`
+	MaxUses     int    `json:"maxUses" binding:"required,min=1,max=1000"`
+	AccountDays int    `json:"accountDays" binding:"required,min=1,max=365"`
+	ValidDays   int    `json:"validDays" binding:"required,min=1,max=90"` // till when the code can be used
+}
+
+type VisitorExpiryRequest struct {
+	ExpiresAt time.Time `json:"expiresAt" binding:"required"`
+}
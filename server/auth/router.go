@@ -3,21 +3,50 @@
 package auth
 
 import (
+	"compass/directory"
 	"compass/middleware"
+	"compass/model"
+	"compass/password"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
 )
 
 func Router(r *gin.Engine) {
+	// Refuse to start rather than store codes hashed with an empty key
+	if err := loadTokenSecret(); err != nil {
+		logrus.Fatalf("One time tokens: %v", err)
+	}
+	if err := password.LoadPolicy(); err != nil {
+		logrus.Fatalf("Password policy: %v", err)
+	}
+	if err := password.LoadHasher(); err != nil {
+		logrus.Fatalf("Password hashing: %v", err)
+	}
+	if err := directory.Load(); err != nil {
+		logrus.Fatalf("Student directory: %v", err)
+	}
+
+	// Public keys for verifying the tokens, used by the other campus services
+	r.GET("/.well-known/jwks.json", middleware.JWKSHandler)
+
 	auth := r.Group("/api/auth")
 	{
 		auth.POST("/login", loginHandler)
+		auth.POST("/login/2fa", loginSecondFactorHandler) // second step, for accounts with two factor enabled
+		auth.POST("/magic-link", magicLinkHandler)
+		auth.POST("/magic-link/verify", magicLoginHandler) // from the link in the mail
 		auth.POST("/signup", signupHandler)
 		auth.GET("/logout", logoutHandler)
 		auth.GET("/verify", verificationHandler)
 		auth.POST("/forgot-password", forgotPasswordHandler)
 		auth.POST("/reset-password", resetPasswordHandler)
+		auth.POST("/resend-verification", resendVerificationHandler)
+		auth.POST("/change-email", middleware.UserAuthenticator, middleware.SessionOnly, changeEmailHandler)
+		auth.POST("/change-email/confirm", confirmEmailChangeHandler) // from the link in the mail, may be opened on any device
+		auth.POST("/impersonate/stop", middleware.EndImpersonation)
 		// Middleware will handel not login state
 		auth.GET("/me", middleware.UserAuthenticator, func(c *gin.Context) {
 			val, exists := c.Get("visibility")
@@ -29,22 +58,90 @@ func Router(r *gin.Engine) {
 				isVisible = false 
 			}
 
+			// Frontend uses them to show the admin / coordinator options
+			permissions, _ := middleware.UserPermissions(c.MustGet("userID").(uuid.UUID), model.Role(c.GetInt("userRole")))
+			// Set while an admin is viewing as the user, frontend shows the banner to stop it
+			impersonator, _ := c.Get("impersonator")
+
 			if isVisible {
 				// 200: logged in + visible
-				c.JSON(http.StatusOK, gin.H{"success": true})
+				c.JSON(http.StatusOK, gin.H{"success": true, "permissions": permissions, "impersonator": impersonator})
 			} else {
 				// 202: logged in + hidden 
-				c.JSON(http.StatusAccepted, gin.H{"success": true, "status": "hidden"})
+				c.JSON(http.StatusAccepted, gin.H{"success": true, "status": "hidden", "permissions": permissions, "impersonator": impersonator})
 			}
 		})
 	}
+	// Devices the user is logged in from
+	sessions := r.Group("/api/auth/sessions")
+	{
+		sessions.Use(middleware.UserAuthenticator, middleware.SessionOnly)
+		sessions.GET("", listSessionsHandler)
+		sessions.DELETE("/:id", revokeSessionHandler)
+		sessions.DELETE("", revokeAllSessionsHandler) // log out all devices
+	}
+	// Personal access tokens for scripts, sent as Authorization: Bearer <token>
+	tokens := r.Group("/api/auth/tokens")
+	{
+		tokens.Use(middleware.UserAuthenticator, middleware.SessionOnly)
+		tokens.GET("", listAccessTokensHandler)
+		tokens.POST("", createAccessTokenHandler)
+		tokens.DELETE("/:id", revokeAccessTokenHandler)
+	}
+	// Two factor (TOTP) enrollment, mandatory for admins and opt-in for users
+	twoFactor := r.Group("/api/auth/2fa")
+	{
+		twoFactor.Use(middleware.UserAuthenticator, middleware.SessionOnly)
+		twoFactor.POST("/setup", setupTwoFactorHandler)
+		twoFactor.POST("/confirm", confirmTwoFactorHandler)
+		twoFactor.POST("/disable", disableTwoFactorHandler)
+		twoFactor.POST("/recovery-codes", regenerateRecoveryCodesHandler)
+	}
+	// Super admin api, managing role assignments
+	roles := r.Group("/api/auth/roles")
+	{
+		roles.Use(middleware.UserAuthenticator, middleware.Require(model.PermRoleManage))
+		roles.GET("", listRolesHandler)
+		roles.GET("/permissions", listPermissionsHandler)
+		roles.GET("/users/:id", userRolesHandler)
+		roles.POST("/users/:id", assignRoleHandler)
+		roles.DELETE("/users/:id/:role", unassignRoleHandler)
+	}
+	// Admin user management
+	users := r.Group("/api/auth/admin/users")
+	{
+		users.Use(middleware.UserAuthenticator, middleware.Require(model.PermUserManage))
+		users.GET("", searchUsersHandler) // ?query=<email or roll no>&page=
+		users.GET("/:id", userDetailsHandler)
+		users.POST("/:id/role", changeUserRoleHandler)
+		users.POST("/:id/suspend", suspendUserHandler)
+		users.POST("/:id/unsuspend", unsuspendUserHandler)
+		users.POST("/:id/verify", forceVerifyUserHandler)
+		users.POST("/:id/reset-password", forcePasswordResetHandler)
+		users.DELETE("/:id", deleteUserHandler)
+		users.POST("/:id/expiry", visitorExpiryHandler) // visitors only
+		users.POST("/:id/impersonate", impersonateUserHandler) // read only "view as user", ends at /api/auth/impersonate/stop
+	}
+	// Invite codes for visitors (parents, alumni, guests)
+	invites := r.Group("/api/auth/admin/invites")
+	{
+		invites.Use(middleware.UserAuthenticator, middleware.Require(model.PermUserManage))
+		invites.GET("", listInvitesHandler)
+		invites.POST("", createInviteHandler)
+		invites.DELETE("/:id", revokeInviteHandler)
+	}
 	profile := r.Group("/api/profile")
 	{
-		profile.Use(middleware.UserAuthenticator)
+		profile.Use(middleware.UserAuthenticator, middleware.SessionOnly)
 		profile.GET("", getProfileHandler)
-		profile.POST("", updateProfile)
-		profile.POST("/pfp", UploadProfileImage)
-		profile.GET("/oa", autoC)
+		// Student profile, visitors do not have one
+		profile.POST("", middleware.Require(model.PermStudentProfile), updateProfile)
+		profile.POST("/pfp", middleware.Require(model.PermStudentProfile), UploadProfileImage)
+		profile.GET("/oa", middleware.Require(model.PermStudentProfile), autoC)
+		// Takeout of everything we hold about the user
+		profile.GET("/export", listExportsHandler)
+		profile.POST("/export", requestExportHandler)
 	}
+	r.GET("/api/profile/export/:id/download", downloadExportHandler) // from the link in the mail
 
 }
@@ -0,0 +1,19 @@
+package auth
+
+import (
+	"compass/middleware"
+	"compass/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Shorthands for middleware.RecordAuthEvent, target is nil when the account is not known
+
+func eventSuccess(c *gin.Context, eventType string, target *uuid.UUID, reason string) {
+	middleware.RecordAuthEvent(c, model.AuthEvent{Type: eventType, Outcome: model.OutcomeSuccess, TargetID: target, Reason: reason})
+}
+
+func eventFailure(c *gin.Context, eventType string, target *uuid.UUID, email string, reason string) {
+	middleware.RecordAuthEvent(c, model.AuthEvent{Type: eventType, Outcome: model.OutcomeFailure, TargetID: target, Email: email, Reason: reason})
+}
@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"compass/connections"
+	"compass/model"
+	"compass/password"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// weakPassword answers 400 with the broken rules when the new password does not follow the policy
+func weakPassword(c *gin.Context, newPassword string, user password.User) bool {
+	violations := password.Default().Check(newPassword, user)
+	if len(violations) == 0 {
+		return false
+	}
+	// error keeps the old frontends working, they show only that
+	c.JSON(http.StatusBadRequest, gin.H{"error": violations[0].Message, "violations": violations})
+	return true
+}
+
+// upgradePasswordHash stores the password hashed with the current hasher, after a successful login.
+// Skipped if the password changed in the meantime (a reset running at the same time).
+func upgradePasswordHash(userID uuid.UUID, oldHash string, plain string) {
+	hash, err := password.Hash(plain)
+	if err != nil {
+		logrus.Errorf("Failed to rehash the password of %s: %v", userID, err)
+		return
+	}
+	if err := connections.DB.Model(&model.User{}).
+		Where("user_id = ? AND password = ?", userID, oldHash).
+		Update("password", hash).Error; err != nil {
+		logrus.Errorf("Failed to upgrade the password hash of %s: %v", userID, err)
+	}
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"compass/connections"
+	"compass/model"
+	"compass/ratelimit"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// The ip limits are loose, a whole hall shares the same ip on the campus network
+var (
+	loginEmailLimiter = ratelimit.New("login:email", ratelimit.Policy{Threshold: 5, BaseLockout: time.Minute, MaxLockout: time.Hour, Window: time.Hour})
+	loginIPLimiter    = ratelimit.New("login:ip", ratelimit.Policy{Threshold: 50, BaseLockout: time.Minute, MaxLockout: 30 * time.Minute, Window: time.Hour})
+	// email otp and the second factor at login, both are 6 digit codes
+	otpUserLimiter = ratelimit.New("otp:user", ratelimit.Policy{Threshold: otpMaxAttempts, BaseLockout: 5 * time.Minute, MaxLockout: time.Hour, Window: time.Hour})
+	otpIPLimiter   = ratelimit.New("otp:ip", ratelimit.Policy{Threshold: 50, BaseLockout: time.Minute, MaxLockout: 30 * time.Minute, Window: time.Hour})
+	// every forgot password / resend request counts, each one sends a mail
+	forgotEmailLimiter = ratelimit.New("forgot:email", ratelimit.Policy{Threshold: 3, BaseLockout: 15 * time.Minute, MaxLockout: 6 * time.Hour, Window: time.Hour})
+	forgotIPLimiter    = ratelimit.New("forgot:ip", ratelimit.Policy{Threshold: 20, BaseLockout: 15 * time.Minute, MaxLockout: 6 * time.Hour, Window: time.Hour})
+	resendEmailLimiter = ratelimit.New("resend:email", ratelimit.Policy{Threshold: 3, BaseLockout: 15 * time.Minute, MaxLockout: 6 * time.Hour, Window: time.Hour})
+	magicEmailLimiter  = ratelimit.New("magic:email", ratelimit.Policy{Threshold: 3, BaseLockout: 15 * time.Minute, MaxLockout: 6 * time.Hour, Window: time.Hour})
+	magicIPLimiter     = ratelimit.New("magic:ip", ratelimit.Policy{Threshold: 20, BaseLockout: 15 * time.Minute, MaxLockout: 6 * time.Hour, Window: time.Hour})
+)
+
+// Wrong guesses after which the emailed otp is thrown away
+const otpMaxAttempts = 5
+
+type attempt struct {
+	limiter *ratelimit.Limiter
+	key     string
+}
+
+// throttled answers 429 when any of the keys is locked out, returns true in that case
+func throttled(c *gin.Context, attempts ...attempt) bool {
+	for _, a := range attempts {
+		retry, err := a.limiter.RetryAfter(a.key)
+		if err != nil {
+			logrus.Errorf("Rate limit %s: %v", a.limiter.Name(), err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return true
+		}
+		if retry > 0 {
+			tooManyAttempts(c, retry)
+			return true
+		}
+	}
+	return false
+}
+
+// recordFailure counts a failed attempt for every key, returns the longest lockout if any key got locked.
+// Lockouts are written into the system logs.
+func recordFailure(c *gin.Context, target *uuid.UUID, attempts ...attempt) time.Duration {
+	var lockout time.Duration
+	for _, a := range attempts {
+		retry, err := a.limiter.Fail(a.key)
+		if err != nil {
+			logrus.Errorf("Rate limit %s: %v", a.limiter.Name(), err)
+			continue
+		}
+		if retry == 0 {
+			continue
+		}
+		lockout = max(lockout, retry)
+		if err := connections.AddLog(connections.DB, model.Logs{
+			Title:       "Locked out after repeated failures",
+			Description: fmt.Sprintf("%s %s locked for %s (from %s)", a.limiter.Name(), a.key, retry.Round(time.Second), c.ClientIP()),
+			ActionTaker: model.Bot.BaseRoleName(),
+			TargetID:    target,
+		}); err != nil {
+			logrus.Errorf("Failed to log the lockout: %v", err)
+		}
+	}
+	return lockout
+}
+
+// clears the failures after a successful attempt
+func resetFailures(attempts ...attempt) {
+	for _, a := range attempts {
+		if err := a.limiter.Reset(a.key); err != nil {
+			logrus.Errorf("Rate limit %s: %v", a.limiter.Name(), err)
+		}
+	}
+}
+
+func tooManyAttempts(c *gin.Context, retry time.Duration) {
+	seconds := int(math.Ceil(retry.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many attempts, please try again later", "retryAfter": seconds})
+}
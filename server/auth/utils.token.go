@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"compass/connections"
+	"compass/model"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errTokenInvalid   = errors.New("invalid token")
+	errTokenExpired   = errors.New("token expired")
+	errTokenExhausted = errors.New("too many wrong attempts")
+)
+
+// 6 digit code, typed by the user
+func generateVerificationToken() (string, error) {
+	// Generate a number between 0 and 999999
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil // always 6 digits
+}
+
+// long random token, only used inside links
+func generateLinkToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Key of the token hashes, set once by loadTokenSecret at start up
+var tokenSecret []byte
+
+// loadTokenSecret reads token.secret, without it the hashes of the 6 digit codes are as good as plain text
+func loadTokenSecret() error {
+	secret := viper.GetString("token.secret")
+	if secret == "" {
+		return errors.New("token.secret is not set")
+	}
+	tokenSecret = []byte(secret)
+	return nil
+}
+
+// Keyed hash, a plain sha256 of a 6 digit code is reversed by trying all the million values
+func hashOneTimeToken(token string) string {
+	mac := hmac.New(sha256.New, tokenSecret)
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// issueToken stores the hash of the token for the user and purpose of pending (Email too, for change_email),
+// any older pending token for the same purpose is replaced. Pass the transaction if any.
+func issueToken(db *gorm.DB, pending model.OneTimeToken, token string, ttl time.Duration) error {
+	if err := db.Where("user_id = ? AND purpose = ? AND consumed_at IS NULL", pending.UserID, pending.Purpose).
+		Delete(&model.OneTimeToken{}).Error; err != nil {
+		return err
+	}
+	pending.TokenHash = hashOneTimeToken(token)
+	pending.ExpiresAt = time.Now().Add(ttl)
+	return db.Create(&pending).Error
+}
+
+// consumeToken checks the token against the pending one and marks it used.
+// A wrong guess is counted, after otpMaxAttempts of them the token stops working.
+func consumeToken(userID uuid.UUID, purpose string, token string) (model.OneTimeToken, error) {
+	var pending model.OneTimeToken
+	wrongGuess := false
+	err := connections.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND purpose = ? AND consumed_at IS NULL", userID, purpose).
+			Order("created_at DESC").
+			First(&pending).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errTokenInvalid
+			}
+			return err
+		}
+		if time.Now().After(pending.ExpiresAt) {
+			return errTokenExpired
+		}
+		if pending.Attempts >= otpMaxAttempts {
+			return errTokenExhausted
+		}
+		if subtle.ConstantTimeCompare([]byte(pending.TokenHash), []byte(hashOneTimeToken(token))) != 1 {
+			wrongGuess = true
+			return tx.Model(&pending).Update("attempts", gorm.Expr("attempts + 1")).Error
+		}
+		now := time.Now()
+		pending.ConsumedAt = &now
+		return tx.Model(&pending).Update("consumed_at", now).Error
+	})
+	if err != nil {
+		return pending, err
+	}
+	if wrongGuess {
+		if pending.Attempts+1 >= otpMaxAttempts {
+			return pending, errTokenExhausted
+		}
+		return pending, errTokenInvalid
+	}
+	return pending, nil
+}
+
+func emailVerificationExpiry() time.Duration {
+	return time.Duration(viper.GetInt("expiry.emailVerification")) * time.Hour
+}
@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"compass/connections"
+	"compass/model"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+// TOTP as per RFC 6238, with the defaults every authenticator app understands:
+// HMAC-SHA1, 6 digits and 30 second steps.
+const (
+	totpPeriod        = 30
+	totpDigits        = 6
+	totpSkew          = 1 // accept one step before and after, for clock drift
+	recoveryCodeCount = 10
+)
+
+var base32NoPad = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// 160 bit secret, the size recommended by RFC 4226
+func generateTOTPSecret() (string, error) {
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return base32NoPad.EncodeToString(secret), nil
+}
+
+// URI to be rendered as a QR code for the authenticator app
+func totpURI(secret string, email string) string {
+	issuer := viper.GetString("twofactor.issuer")
+	if issuer == "" {
+		issuer = "Campus Compass"
+	}
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(totpDigits))
+	params.Set("period", fmt.Sprint(totpPeriod))
+	label := url.PathEscape(issuer + ":" + email)
+	return fmt.Sprintf("otpauth://totp/%s?%s", label, params.Encode())
+}
+
+// HOTP value (RFC 4226) for the given counter
+func totpCode(secret string, counter int64) (string, error) {
+	key, err := base32NoPad.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	// Dynamic truncation
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000), nil
+}
+
+// validateTOTP checks the code around the current time step and returns the matched step.
+// Steps at or before lastCounter are refused so a code can not be replayed.
+func validateTOTP(secret string, code string, lastCounter int64) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != totpDigits {
+		return 0, false
+	}
+	current := time.Now().Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= lastCounter {
+			continue
+		}
+		expected, err := totpCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// replaceRecoveryCodes drops the old codes of the user and returns a fresh set, to be shown only once
+func replaceRecoveryCodes(tx *gorm.DB, userID uuid.UUID) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&model.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	codes := make([]string, 0, recoveryCodeCount)
+	rows := make([]model.RecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw := make([]byte, 5)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		// Format: xxxxx-xxxxx
+		code := hex.EncodeToString(raw)
+		code = code[:5] + "-" + code[5:]
+		codes = append(codes, code)
+		rows = append(rows, model.RecoveryCode{UserID: userID, CodeHash: hashRecoveryCode(code)})
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// verifySecondFactor accepts either a TOTP code or an unused recovery code of the user.
+// Both are consumed atomically, a parallel request with the same code fails.
+func verifySecondFactor(user model.User, code string) (bool, error) {
+	if user.TOTPSecret == "" {
+		return false, nil
+	}
+	if step, ok := validateTOTP(user.TOTPSecret, code, user.TOTPLastCounter); ok {
+		result := connections.DB.
+			Model(&model.User{}).
+			Where("user_id = ? AND totp_last_counter < ?", user.UserID, step).
+			Update("totp_last_counter", step)
+		return result.RowsAffected == 1, result.Error
+	}
+	// Not a valid TOTP, try as a recovery code
+	result := connections.DB.
+		Model(&model.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.UserID, hashRecoveryCode(code)).
+		Update("used_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"compass/model"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errInviteInvalid = errors.New("invite code invalid, expired or used up")
+
+// visitorDomainAllowed tells if the email may sign up as a visitor without an invite code
+func visitorDomainAllowed(email string) bool {
+	_, domain, found := strings.Cut(email, "@")
+	if !found {
+		return false
+	}
+	for _, allowed := range viper.GetStringSlice("visitors.domains") {
+		if strings.EqualFold(domain, strings.TrimPrefix(allowed, "@")) {
+			return true
+		}
+	}
+	return false
+}
+
+// life of the visitor accounts made through the allowed domains
+func visitorAccountDays() int {
+	if days := viper.GetInt("visitors.account_days"); days > 0 {
+		return days
+	}
+	return 30
+}
+
+// redeemInvite uses up one use of the invite, call it inside the signup transaction.
+// Returns the life of the account in days.
+func redeemInvite(tx *gorm.DB, code string) (int, error) {
+	var invite model.VisitorInvite
+	result := tx.Model(&invite).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "account_days"}}}).
+		Where("code_hash = ? AND revoked_at IS NULL AND expires_at > ? AND uses < max_uses", hashOneTimeToken(code), time.Now()).
+		Update("uses", gorm.Expr("uses + 1"))
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return 0, errInviteInvalid
+	}
+	return invite.AccountDays, nil
+}
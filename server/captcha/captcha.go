@@ -0,0 +1,133 @@
+// Captcha verification for the public forms (login, signup, forgot password)
+// The provider is picked from the config, use "fake" for dev and CI
+package captcha
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Actions sent by the frontend while executing the widget
+const (
+	ActionLogin          = "login"
+	ActionSignup         = "signup"
+	ActionForgotPassword = "forgot_password"
+	ActionMagicLink      = "magic_link"
+)
+
+var (
+	ErrFailed   = errors.New("captcha rejected by the provider")
+	ErrScore    = errors.New("captcha score below the threshold")
+	ErrAction   = errors.New("captcha solved for a different action")
+	ErrHostname = errors.New("captcha solved on an unknown hostname")
+)
+
+// Response of the siteverify api, same shape for reCAPTCHA, hCaptcha and Turnstile
+type Response struct {
+	Success     bool     `json:"success"`      // whether this request was a valid token for your site
+	Score       float64  `json:"score"`        // the score for this request (0.0 - 1.0), reCAPTCHA v3 only
+	Action      string   `json:"action"`       // the action name for this request (important to verify)
+	ChallengeTS string   `json:"challenge_ts"` // timestamp of the challenge load (ISO format yyyy-MM-dd'T'HH:mm:ssZZ)
+	Hostname    string   `json:"hostname"`     // the hostname of the site where the captcha was solved
+	ErrorCodes  []string `json:"error-codes"`  // optional
+	// Set by the verifier when the score means something, hCaptcha scores are risk scores (enterprise only)
+	Scored bool `json:"-"`
+}
+
+type Verifier interface {
+	// Verify asks the provider about the token, the fields are checked later by Check
+	Verify(token string, remoteIP string) (Response, error)
+}
+
+var (
+	verifier     Verifier
+	verifierOnce sync.Once
+)
+
+// NewVerifier builds the verifier for the given provider: recaptcha, hcaptcha, turnstile or fake
+func NewVerifier(provider string, secret string) (Verifier, error) {
+	switch provider {
+	case "recaptcha", "":
+		return siteVerify{url: "https://www.google.com/recaptcha/api/siteverify", secret: secret, scored: true}, nil
+	case "hcaptcha":
+		return siteVerify{url: "https://api.hcaptcha.com/siteverify", secret: secret}, nil
+	case "turnstile":
+		return siteVerify{url: "https://challenges.cloudflare.com/turnstile/v0/siteverify", secret: secret}, nil
+	case "fake":
+		return Fake{}, nil
+	}
+	return nil, fmt.Errorf("unknown captcha provider %q", provider)
+}
+
+// configured verifier, built on the first use so that viper is loaded by then
+func defaultVerifier() Verifier {
+	verifierOnce.Do(func() {
+		secret := viper.GetString("captcha.secret")
+		if secret == "" {
+			// older secret files
+			secret = viper.GetString("recaptcha.key")
+		}
+		v, err := NewVerifier(viper.GetString("captcha.provider"), secret)
+		if err != nil {
+			// Never fall back to fake, a typo in the config should not open the forms
+			logrus.Fatalf("Captcha: %v", err)
+		}
+		verifier = v
+	})
+	return verifier
+}
+
+// Check verifies the token with the configured provider for the given action
+func Check(token string, action string, remoteIP string) error {
+	return CheckWith(defaultVerifier(), token, action, remoteIP)
+}
+
+// CheckWith verifies the token and validates the score, action and hostname of the response
+func CheckWith(v Verifier, token string, action string, remoteIP string) error {
+	if token == "" {
+		return ErrFailed
+	}
+	resp, err := v.Verify(token, remoteIP)
+	if err != nil {
+		return err
+	}
+	if !resp.Success {
+		logrus.Debugf("Captcha rejected: %v", resp.ErrorCodes)
+		return ErrFailed
+	}
+	if resp.Scored && resp.Score < threshold() {
+		return ErrScore
+	}
+	// Providers return the action only when the widget was executed with one
+	if resp.Action != "" && resp.Action != action {
+		return ErrAction
+	}
+	if !allowedHostname(resp.Hostname) {
+		return ErrHostname
+	}
+	return nil
+}
+
+func threshold() float64 {
+	if !viper.IsSet("captcha.threshold") {
+		return 0.5
+	}
+	return viper.GetFloat64("captcha.threshold")
+}
+
+// Same rules as the CORS middleware: localhost, the domain and its subdomains
+func allowedHostname(hostname string) bool {
+	domain := viper.GetString("domain")
+	if hostname == "" {
+		return false
+	}
+	if hostname == "localhost" {
+		return true
+	}
+	return domain != "" && (hostname == domain || strings.HasSuffix(hostname, "."+domain))
+}
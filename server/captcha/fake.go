@@ -0,0 +1,39 @@
+package captcha
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Fake never leaves the machine, the response is derived from the token itself.
+// Any token passes, unless it is "fail" or overrides the fields as "key=value" pairs separated by ";",
+// e.g. "score=0.2", "action=signup;hostname=evil.com". Only for dev and CI.
+type Fake struct{}
+
+func (Fake) Verify(token string, remoteIP string) (Response, error) {
+	resp := Response{Success: true, Score: 1, Hostname: "localhost", Scored: true}
+	if token == "fail" {
+		resp.Success = false
+		resp.ErrorCodes = []string{"invalid-input-response"}
+		return resp, nil
+	}
+	for _, pair := range strings.Split(token, ";") {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "score":
+			if score, err := strconv.ParseFloat(value, 64); err == nil {
+				resp.Score = score
+			}
+		case "action":
+			resp.Action = value
+		case "hostname":
+			resp.Hostname = value
+		case "success":
+			resp.Success = value == "true"
+		}
+	}
+	return resp, nil
+}
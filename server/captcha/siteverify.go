@@ -0,0 +1,40 @@
+package captcha
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// siteVerify talks to the siteverify endpoint, reCAPTCHA, hCaptcha and Turnstile share the same api
+type siteVerify struct {
+	url    string
+	secret string
+	scored bool
+}
+
+func (s siteVerify) Verify(token string, remoteIP string) (Response, error) {
+	form := url.Values{"secret": {s.secret}, "response": {token}}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+	resp, err := httpClient.PostForm(s.url, form)
+	if err != nil {
+		return Response{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Response{}, fmt.Errorf("captcha provider returned %d", resp.StatusCode)
+	}
+
+	var result Response
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return Response{}, err
+	}
+	result.Scored = s.scored
+	return result, nil
+}
@@ -0,0 +1,242 @@
+// Fake relying party for testing "Login with Compass" end to end on a local set up.
+// Register a client with the redirect uri http://localhost:9090/callback, then
+//
+//	go run ./cmd/fakerp -issuer http://localhost:8080 -client-id <id> [-client-secret <secret>]
+//
+// and open http://localhost:9090/login. The verified id token and the userinfo response are printed.
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	issuer       = flag.String("issuer", "http://localhost:8080", "issuer of the compass auth server")
+	clientID     = flag.String("client-id", "", "client id of the registered app")
+	clientSecret = flag.String("client-secret", "", "client secret, empty for public clients")
+	listen       = flag.String("listen", "localhost:9090", "address of this relying party")
+	scope        = flag.String("scope", "openid email profile roll_number", "scopes to ask for")
+)
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// state of the logins in flight, keyed by the state parameter
+type pending struct {
+	nonce    string
+	verifier string
+}
+
+var (
+	mu       sync.Mutex
+	inFlight = map[string]pending{}
+	config   discovery
+)
+
+func main() {
+	flag.Parse()
+	if *clientID == "" {
+		log.Fatal("-client-id is required")
+	}
+	if err := getJSON(strings.TrimSuffix(*issuer, "/")+"/.well-known/openid-configuration", &config); err != nil {
+		log.Fatal("Failed to fetch the discovery document: ", err)
+	}
+	if config.Issuer != *issuer {
+		log.Fatalf("Issuer mismatch, discovery says %q", config.Issuer)
+	}
+
+	http.HandleFunc("/login", loginHandler)
+	http.HandleFunc("/callback", callbackHandler)
+	log.Printf("Open http://%s/login", *listen)
+	log.Fatal(http.ListenAndServe(*listen, nil))
+}
+
+func redirectURI() string {
+	return "http://" + *listen + "/callback"
+}
+
+func loginHandler(w http.ResponseWriter, r *http.Request) {
+	state, nonce, verifier := random(), random(), random()
+	mu.Lock()
+	inFlight[state] = pending{nonce: nonce, verifier: verifier}
+	mu.Unlock()
+
+	challenge := sha256.Sum256([]byte(verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {*clientID},
+		"redirect_uri":          {redirectURI()},
+		"scope":                 {*scope},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	if prompt := r.URL.Query().Get("prompt"); prompt != "" {
+		query.Set("prompt", prompt)
+	}
+	http.Redirect(w, r, config.AuthorizationEndpoint+"?"+query.Encode(), http.StatusFound)
+}
+
+func callbackHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	mu.Lock()
+	login, ok := inFlight[query.Get("state")]
+	delete(inFlight, query.Get("state"))
+	mu.Unlock()
+	if !ok {
+		http.Error(w, "unknown state", http.StatusBadRequest)
+		return
+	}
+	if e := query.Get("error"); e != "" {
+		http.Error(w, e+": "+query.Get("error_description"), http.StatusBadRequest)
+		return
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {query.Get("code")},
+		"redirect_uri":  {redirectURI()},
+		"code_verifier": {login.verifier},
+	}
+	// confidential clients use client_secret_basic, public ones only send their id
+	if *clientSecret == "" {
+		form.Set("client_id", *clientID)
+	}
+	req, _ := http.NewRequest(http.MethodPost, config.TokenEndpoint, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if *clientSecret != "" {
+		req.SetBasicAuth(*clientID, *clientSecret)
+	}
+	var tokens struct {
+		AccessToken string `json:"access_token"`
+		IDToken     string `json:"id_token"`
+		Scope       string `json:"scope"`
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+	if err := doJSON(req, &tokens); err != nil || tokens.Error != "" {
+		http.Error(w, fmt.Sprintf("token exchange failed: %v %s %s", err, tokens.Error, tokens.Description), http.StatusBadGateway)
+		return
+	}
+
+	keys, err := fetchKeys()
+	if err != nil {
+		http.Error(w, "failed to fetch jwks: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	idClaims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(tokens.IDToken, idClaims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		if key, ok := keys[kid]; ok {
+			return key, nil
+		}
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}, jwt.WithValidMethods([]string{"ES256", "EdDSA"}), jwt.WithIssuer(config.Issuer), jwt.WithAudience(*clientID))
+	if err != nil {
+		http.Error(w, "invalid id token: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	if idClaims["nonce"] != login.nonce {
+		http.Error(w, "nonce mismatch", http.StatusBadGateway)
+		return
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, config.UserinfoEndpoint, nil)
+	req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	userinfo := map[string]any{}
+	if err := doJSON(req, &userinfo); err != nil {
+		http.Error(w, "userinfo failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(map[string]any{"scope": tokens.Scope, "id_token": idClaims, "userinfo": userinfo})
+	log.Printf("Logged in %v", idClaims["sub"])
+}
+
+// Public keys of the jwks, keyed by kid
+func fetchKeys() (map[string]any, error) {
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := getJSON(config.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+	keys := map[string]any{}
+	for _, k := range set.Keys {
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case k.Kty == "EC" && k.Crv == "P-256":
+			y, err := base64.RawURLEncoding.DecodeString(k.Y)
+			if err != nil {
+				return nil, err
+			}
+			keys[k.Kid] = &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		case k.Kty == "OKP" && k.Crv == "Ed25519":
+			keys[k.Kid] = ed25519.PublicKey(x)
+		default:
+			return nil, errors.New("unsupported key " + k.Kty + "/" + k.Crv)
+		}
+	}
+	return keys, nil
+}
+
+func getJSON(url string, v any) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	return doJSON(req, v)
+}
+
+func doJSON(req *http.Request, v any) error {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("%s: %w", resp.Status, err)
+	}
+	return nil
+}
+
+func random() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,347 @@
+// import-geojson subcommand, seeds the locations from an OSM GeoJSON export (replaces parser/parse.py).
+//
+//	server import-geojson -file ./parser/locations.geojson -contributor <user id> [-dry-run]
+//
+// A feature close to an existing location with a similar name updates it instead of adding a duplicate.
+// Everything is written in one transaction, -dry-run only prints the diff.
+package main
+
+import (
+	"compass/connections"
+	"compass/model"
+	"compass/routing"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+// OSM properties read for each location field, the first non empty one wins
+type propertyMap struct {
+	Name         []string
+	LocationType []string
+	Tag          []string
+	Contact      []string
+	Time         []string
+	Skip         []string // features with any of these are not locations (roads, power lines)
+}
+
+func importProperties() propertyMap {
+	keys := func(key string, fallback ...string) []string {
+		if viper.IsSet("import.properties." + key) {
+			return viper.GetStringSlice("import.properties." + key)
+		}
+		return fallback
+	}
+	return propertyMap{
+		Name:         keys("name", "name", "name:en", "short_name"),
+		LocationType: keys("location_type", "amenity", "shop", "leisure", "office", "tourism", "healthcare", "building"),
+		Tag:          keys("tag", "cuisine", "sport", "operator"),
+		Contact:      keys("contact", "phone", "contact:phone", "email", "contact:email", "website"),
+		Time:         keys("time", "opening_hours"),
+		Skip:         keys("skip", "highway", "railway", "power", "barrier"),
+	}
+}
+
+type importFeature struct {
+	Geometry struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	} `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// A planned write, existing is false for a new location
+type importChange struct {
+	location *model.Location
+	existing bool
+	fields   map[string]interface{} // column -> new value, for the updates
+	diff     []string
+}
+
+func importGeoJSON(args []string) error {
+	flags := flag.NewFlagSet("import-geojson", flag.ExitOnError)
+	file := flags.String("file", "./parser/locations.geojson", "GeoJSON FeatureCollection to import")
+	dryRun := flags.Bool("dry-run", false, "print the changes without writing them")
+	contributor := flags.String("contributor", "", "user id credited for the new locations")
+	maxDistance := flags.Float64("distance", 50, "metres, a location further than this is never the same one")
+	minSimilarity := flags.Float64("similarity", 0.7, "0 to 1, name similarity for a nearby location to be the same one")
+	status := flags.String("status", string(model.Approved), "status of the new locations, approved or pending")
+	flags.Parse(args)
+
+	if *status != string(model.Approved) && *status != string(model.Pending) {
+		return fmt.Errorf("invalid status %q", *status)
+	}
+	var contributorID uuid.UUID
+	if !*dryRun {
+		id, err := uuid.Parse(*contributor)
+		if err != nil {
+			return errors.New("-contributor must be a user id")
+		}
+		if err := connections.DB.Select("user_id").Where("user_id = ?", id).First(&model.User{}).Error; err != nil {
+			return fmt.Errorf("contributor %s not found: %w", id, err)
+		}
+		contributorID = id
+	}
+
+	data, err := os.ReadFile(*file)
+	if err != nil {
+		return err
+	}
+	var collection struct {
+		Type     string          `json:"type"`
+		Features []importFeature `json:"features"`
+	}
+	if err := json.Unmarshal(data, &collection); err != nil {
+		return fmt.Errorf("invalid geojson: %w", err)
+	}
+	if collection.Type != "FeatureCollection" {
+		return fmt.Errorf("expected a FeatureCollection, got %q", collection.Type)
+	}
+
+	var existing []model.Location
+	if err := connections.DB.Find(&existing).Error; err != nil {
+		return fmt.Errorf("failed to fetch the locations: %w", err)
+	}
+	// New locations join the candidates too, the same place is often both a Point and a Polygon in OSM
+	candidates := make([]*importChange, len(existing))
+	for i := range existing {
+		candidates[i] = &importChange{location: &existing[i], existing: true, fields: map[string]interface{}{}}
+	}
+
+	properties := importProperties()
+	skipped := 0
+	for _, feature := range collection.Features {
+		tags := stringTags(feature.Properties)
+		name := firstTag(tags, properties.Name)
+		if name == "" || hasAny(tags, properties.Skip) {
+			skipped++
+			continue
+		}
+		lat, lng, err := centroid(feature.Geometry.Coordinates)
+		if err != nil {
+			logrus.Warnf("Skipping %q: %v", name, err)
+			skipped++
+			continue
+		}
+		imported := model.Location{
+			Name:         name,
+			Latitude:     float32(lat),
+			Longitude:    float32(lng),
+			LocationType: firstTag(tags, properties.LocationType),
+			Tag:          firstTag(tags, properties.Tag),
+			Contact:      firstTag(tags, properties.Contact),
+			Time:         firstTag(tags, properties.Time),
+		}
+
+		if match := bestMatch(candidates, imported, *maxDistance, *minSimilarity); match != nil {
+			match.merge(imported)
+			continue
+		}
+		imported.Status = model.Status(*status)
+		imported.ContributedBy = contributorID
+		change := &importChange{location: &imported}
+		change.diff = append(change.diff, fmt.Sprintf("+ %s (%.6f, %.6f) type=%q tag=%q contact=%q time=%q",
+			name, lat, lng, imported.LocationType, imported.Tag, imported.Contact, imported.Time))
+		candidates = append(candidates, change)
+	}
+
+	var created, updated int
+	for _, change := range candidates {
+		if len(change.diff) == 0 {
+			continue
+		}
+		if change.existing {
+			updated++
+		} else {
+			created++
+		}
+		for _, line := range change.diff {
+			fmt.Println(line)
+		}
+	}
+	fmt.Printf("%d to add, %d to update, %d features skipped\n", created, updated, skipped)
+	if *dryRun || created+updated == 0 {
+		return nil
+	}
+
+	err = connections.DB.Transaction(func(tx *gorm.DB) error {
+		for _, change := range candidates {
+			switch {
+			case !change.existing:
+				if err := tx.Create(change.location).Error; err != nil {
+					return fmt.Errorf("failed to add %q: %w", change.location.Name, err)
+				}
+			case len(change.fields) > 0:
+				if err := tx.Model(&model.Location{}).Where("location_id = ?", change.location.LocationId).
+					Updates(change.fields).Error; err != nil {
+					return fmt.Errorf("failed to update %q: %w", change.location.Name, err)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	logrus.Infof("Imported %s: %d locations added, %d updated", *file, created, updated)
+	return nil
+}
+
+// bestMatch is the most similar named location within maxDistance, nil when none is similar enough
+func bestMatch(candidates []*importChange, imported model.Location, maxDistance, minSimilarity float64) *importChange {
+	point := routing.Point{Lat: float64(imported.Latitude), Lng: float64(imported.Longitude)}
+	var best *importChange
+	bestScore := minSimilarity
+	for _, candidate := range candidates {
+		location := candidate.location
+		if routing.Distance(point, routing.Point{Lat: float64(location.Latitude), Lng: float64(location.Longitude)}) > maxDistance {
+			continue
+		}
+		if score := nameSimilarity(location.Name, imported.Name); score >= bestScore {
+			best, bestScore = candidate, score
+		}
+	}
+	return best
+}
+
+// merge fills the fields the import has a value for. The name and the position stay,
+// they may have been corrected by hand and are what matched.
+func (change *importChange) merge(imported model.Location) {
+	location := change.location
+	set := func(column, label string, current *string, value string) {
+		if value == "" || value == *current {
+			return
+		}
+		if change.existing {
+			change.diff = append(change.diff, fmt.Sprintf("~ %s [%s] %s: %q -> %q", location.Name, location.LocationId, label, *current, value))
+			change.fields[column] = value
+		}
+		*current = value
+	}
+	set("location_type", "type", &location.LocationType, imported.LocationType)
+	set("tag", "tag", &location.Tag, imported.Tag)
+	set("contact", "contact", &location.Contact, imported.Contact)
+	set("time", "time", &location.Time, imported.Time)
+	if !change.existing && len(change.diff) > 0 {
+		// A new location merged with another feature, show the final values
+		change.diff[0] = fmt.Sprintf("+ %s (%.6f, %.6f) type=%q tag=%q contact=%q time=%q",
+			location.Name, location.Latitude, location.Longitude, location.LocationType, location.Tag, location.Contact, location.Time)
+	}
+}
+
+// nameSimilarity is 1 - edit distance / length, over the lower case letters and digits.
+// "Hall 1", "HALL-1" and "hall1" are the same name.
+func nameSimilarity(a, b string) float64 {
+	x, y := []rune(normalizeName(a)), []rune(normalizeName(b))
+	if len(x) == 0 || len(y) == 0 {
+		return 0
+	}
+	longest := len(x)
+	if len(y) > longest {
+		longest = len(y)
+	}
+	// Levenshtein with a single row
+	row := make([]int, len(y)+1)
+	for j := range row {
+		row[j] = j
+	}
+	for i := 1; i <= len(x); i++ {
+		diagonal := row[0]
+		row[0] = i
+		for j := 1; j <= len(y); j++ {
+			cost := 1
+			if x[i-1] == y[j-1] {
+				cost = 0
+			}
+			above := row[j]
+			row[j] = min(row[j]+1, row[j-1]+1, diagonal+cost)
+			diagonal = above
+		}
+	}
+	return 1 - float64(row[len(y)])/float64(longest)
+}
+
+func normalizeName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, s)
+}
+
+func stringTags(properties map[string]interface{}) map[string]string {
+	tags := make(map[string]string, len(properties))
+	for k, v := range properties {
+		if s, ok := v.(string); ok {
+			tags[k] = strings.TrimSpace(s)
+		}
+	}
+	return tags
+}
+
+// firstTag is the first key with a value, "yes" only says the key applies (building=yes) so it is skipped
+func firstTag(tags map[string]string, keys []string) string {
+	for _, key := range keys {
+		if v := tags[key]; v != "" && v != "yes" {
+			return v
+		}
+	}
+	return ""
+}
+
+func hasAny(tags map[string]string, keys []string) bool {
+	for _, key := range keys {
+		if _, ok := tags[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// centroid is the average of all the positions of the geometry, for a Point it is the point itself
+func centroid(raw json.RawMessage) (float64, float64, error) {
+	var coordinates interface{}
+	if err := json.Unmarshal(raw, &coordinates); err != nil {
+		return 0, 0, err
+	}
+	var latSum, lngSum float64
+	var count int
+	var walk func(v interface{}) error
+	walk = func(v interface{}) error {
+		list, ok := v.([]interface{})
+		if !ok || len(list) == 0 {
+			return errors.New("invalid coordinates")
+		}
+		if lng, ok := list[0].(float64); ok {
+			if len(list) < 2 {
+				return errors.New("invalid position")
+			}
+			lat, ok := list[1].(float64)
+			if !ok {
+				return errors.New("invalid position")
+			}
+			latSum, lngSum, count = latSum+lat, lngSum+lng, count+1
+			return nil
+		}
+		for _, item := range list {
+			if err := walk(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(coordinates); err != nil {
+		return 0, 0, err
+	}
+	return latSum / float64(count), lngSum / float64(count), nil
+}
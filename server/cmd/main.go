@@ -3,6 +3,7 @@ package main
 import (
 	_ "compass/connections" // is a blank import and it runs the init() functions in the package
 	"compass/workers"
+	"os"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -16,6 +17,19 @@ const (
 )
 
 func main() {
+	// Subcommands run once and exit, without the servers
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "import-geojson":
+			if err := importGeoJSON(os.Args[2:]); err != nil {
+				logrus.Fatal("Import failed: ", err)
+			}
+		default:
+			logrus.Fatalf("Unknown command %q, available: import-geojson", os.Args[1])
+		}
+		return
+	}
+
 	// Create an error group to handle errors together
 	var g errgroup.Group
 
@@ -33,6 +47,9 @@ func main() {
 	g.Go(func() error {
 		return workers.MailingWorker()
 	})
+	g.Go(func() error {
+		return workers.ExportWorker()
+	})
 	g.Go(func() error {
 		return workers.CleanupWorker()
 	})
@@ -9,6 +9,7 @@ import (
 	"github.com/spf13/viper"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"strings"
 )
 
 var DB *gorm.DB
@@ -39,12 +40,48 @@ func dbConnection() {
 		&model.Image{},
 		&model.Profile{},
 		&model.ChangeLog{},
+		&model.Session{},
+		&model.RecoveryCode{},
+		&model.Permission{},
+		&model.AccessRole{},
+		&model.RoleAssignment{},
+		&model.RateLimit{},
+		&model.OneTimeToken{},
+		&model.OAuthClient{},
+		&model.OAuthConsent{},
+		&model.OAuthCode{},
+		&model.PersonalAccessToken{},
+		&model.VisitorInvite{},
+		&model.Bookmark{},
+		&model.DataExport{},
+		&model.AuthEvent{},
+		&model.LocationEdit{},
+		&model.LocationVersion{},
 	}
 
 	if err := DB.AutoMigrate(models...); err != nil {
 		logrus.Fatal("Failed to auto-migrate models: ", err)
 	}
+	// Plaintext tokens from before the one_time_tokens table, nothing reads them any more.
+	// Dropping loses data, so it only runs when migrations.drop_verification_token is turned on (see config.yaml)
+	if viper.GetBool("migrations.drop_verification_token") && DB.Migrator().HasColumn(&model.User{}, "verification_token") {
+		logrus.Info("Dropping users.verification_token, the users with a pending verification need a new mail")
+		if err := DB.Migrator().DropColumn(&model.User{}, "verification_token"); err != nil {
+			logrus.Error("Failed to drop users.verification_token: ", err)
+		}
+	}
+	// AutoMigrate keeps an existing check constraint as it is, changesRequested was added to the location status later
+	var statusCheck string
+	DB.Raw("SELECT pg_get_constraintdef(oid) FROM pg_constraint WHERE conname = 'chk_locations_status'").Scan(&statusCheck)
+	if statusCheck != "" && !strings.Contains(statusCheck, string(model.ChangesRequested)) {
+		if err := DB.Migrator().DropConstraint(&model.Location{}, "chk_locations_status"); err != nil {
+			logrus.Error("Failed to drop the location status check: ", err)
+		} else if err := DB.Migrator().CreateConstraint(&model.Location{}, "chk_locations_status"); err != nil {
+			logrus.Error("Failed to create the location status check: ", err)
+		}
+	}
 	DB.Exec("CREATE EXTENSION IF NOT EXISTS pgcrypto")
 	DB.Exec("CREATE EXTENSION IF NOT EXISTS pg_trgm")
+	seedAccessRoles()
 	logrus.Info("Connected to database")
 }
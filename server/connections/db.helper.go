@@ -1,6 +1,11 @@
 package connections
 
-import "gorm.io/gorm"
+import (
+	"compass/model"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
 
 func UserSelect(db *gorm.DB) *gorm.DB {
 	return db.Omit("profile").Select("user_id")
@@ -43,3 +48,11 @@ func ImageSelect(db *gorm.DB) *gorm.DB {
 		Where("parent_asset_id IS NOT NULL").
 		Select("image_id", "status", "owner_id")
 }
+
+// AddLog writes an entry in the system logs (model.Logs), pass the transaction if any
+func AddLog(db *gorm.DB, entry model.Logs) error {
+	if entry.LogId == "" {
+		entry.LogId = uuid.NewString()
+	}
+	return db.Create(&entry).Error
+}
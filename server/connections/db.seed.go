@@ -0,0 +1,86 @@
+// Seed the static data the application expects in the database (permissions, built in roles)
+package connections
+
+import (
+	"compass/model"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func seedAccessRoles() {
+	// Permissions added by this release are granted to the built in roles which already exist
+	var known []string
+	if err := DB.Model(&model.Permission{}).Pluck("name", &known).Error; err != nil {
+		logrus.Fatal("Failed to seed permissions: ", err)
+	}
+	for name, description := range model.PermissionDescriptions {
+		if err := DB.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"description"}),
+		}).Create(&model.Permission{Name: name, Description: description}).Error; err != nil {
+			logrus.Fatal("Failed to seed permissions: ", err)
+		}
+	}
+
+	// Only the missing roles are created, so the changes done through the api survive restarts
+	for name, permissions := range model.DefaultRoles {
+		var role model.AccessRole
+		err := DB.First(&role, "name = ?", name).Error
+		if err == nil {
+			var added []model.Permission
+			for _, p := range permissions {
+				if !slices.Contains(known, p) {
+					added = append(added, model.Permission{Name: p})
+				}
+			}
+			if len(added) > 0 {
+				if err := DB.Model(&role).Omit("Permissions.*").Association("Permissions").Append(added); err != nil {
+					logrus.Fatal("Failed to seed roles: ", err)
+				}
+			}
+			continue
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			logrus.Fatal("Failed to seed roles: ", err)
+		}
+		role = model.AccessRole{Name: name}
+		for _, p := range permissions {
+			role.Permissions = append(role.Permissions, model.Permission{Name: p})
+		}
+		if err := DB.Omit("Permissions.*").Create(&role).Error; err != nil {
+			logrus.Fatal("Failed to seed roles: ", err)
+		}
+	}
+
+	// Super admins hold every permission, including the ones added after their role was created
+	var all []model.Permission
+	if err := DB.Find(&all).Error; err != nil {
+		logrus.Fatal("Failed to seed roles: ", err)
+	}
+	if err := DB.Model(&model.AccessRole{Name: model.RoleNameSuperAdmin}).Omit("Permissions.*").Association("Permissions").Append(all); err != nil {
+		logrus.Fatal("Failed to seed roles: ", err)
+	}
+
+	// Bootstrap super admins from the config, they can then assign roles through the api
+	for _, email := range viper.GetStringSlice("roles.superadmins") {
+		var user model.User
+		if err := DB.Select("user_id").First(&user, "email = ?", strings.ToLower(email)).Error; err != nil {
+			logrus.Warnf("Super admin %s is not registered yet", email)
+			continue
+		}
+		if err := DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.RoleAssignment{
+			UserID:     user.UserID,
+			RoleName:   model.RoleNameSuperAdmin,
+			AssignedBy: user.UserID,
+		}).Error; err != nil {
+			logrus.Error("Failed to assign super admin role: ", err)
+		}
+	}
+	logrus.Info("Seeded roles and permissions")
+}
@@ -55,6 +55,19 @@ func initRabbitMQ() {
 	if err != nil {
 		log.Fatalf("Failed to declare moderation queue: %v", err)
 	}
+	// Declare Export Queue
+	exportQueue := viper.GetString("rabbitmq.exportqueue")
+	_, err = MQChannel.QueueDeclare(
+		exportQueue,
+		true,  // durable
+		false, // autoDelete
+		false, // exclusive
+		false, // noWait
+		nil,   // arguments
+	)
+	if err != nil {
+		log.Fatalf("Failed to declare export queue: %v", err)
+	}
 	logrus.Info("Set up done for rabbitmq...")
 
 }
@@ -14,6 +14,8 @@ import (
 func viperConfig() {
 	viper.SetConfigType("yaml")
 	viper.AddConfigPath("./")
+	// go test runs in the directory of the package
+	viper.AddConfigPath("../")
 
 	viper.SetConfigName("config")
 	err := viper.ReadInConfig()
@@ -0,0 +1,107 @@
+package directory
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entries older than this are dropped, till then they are served when the backend is down
+const staleFor = 30 * 24 * time.Hour
+
+// Cached remembers the successful answers of the backend for the ttl.
+// When the backend is unavailable an older answer is used instead, so the users
+// who verified earlier can keep editing their profile while OA is down.
+type Cached struct {
+	next      StudentDirectory
+	ttl       time.Duration
+	mu        sync.Mutex
+	verified  map[Claim]time.Time
+	students  map[string]cachedStudent
+	lastSweep time.Time
+}
+
+type cachedStudent struct {
+	student  Student
+	loadedAt time.Time
+}
+
+func NewCached(next StudentDirectory, ttl time.Duration) *Cached {
+	return &Cached{
+		next:      next,
+		ttl:       ttl,
+		verified:  map[Claim]time.Time{},
+		students:  map[string]cachedStudent{},
+		lastSweep: time.Now(),
+	}
+}
+
+func (d *Cached) Verify(claim Claim) error {
+	key := Claim{
+		Email:  strings.ToLower(claim.Email),
+		RollNo: normalize(claim.RollNo),
+		Name:   normalize(claim.Name),
+		Dept:   normalize(claim.Dept),
+		Course: normalize(claim.Course),
+	}
+	d.mu.Lock()
+	verifiedAt, ok := d.verified[key]
+	d.mu.Unlock()
+	if ok && time.Since(verifiedAt) < d.ttl {
+		return nil
+	}
+
+	err := d.next.Verify(claim)
+	if err == ErrUnavailable && ok && time.Since(verifiedAt) < staleFor {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.verified[key] = time.Now()
+	d.sweep()
+	d.mu.Unlock()
+	return nil
+}
+
+func (d *Cached) Lookup(email string) (Student, error) {
+	email = strings.ToLower(email)
+	d.mu.Lock()
+	entry, ok := d.students[email]
+	d.mu.Unlock()
+	if ok && time.Since(entry.loadedAt) < d.ttl {
+		return entry.student, nil
+	}
+
+	student, err := d.next.Lookup(email)
+	if err == ErrUnavailable && ok && time.Since(entry.loadedAt) < staleFor {
+		return entry.student, nil
+	}
+	if err != nil {
+		return Student{}, err
+	}
+	d.mu.Lock()
+	d.students[email] = cachedStudent{student: student, loadedAt: time.Now()}
+	d.sweep()
+	d.mu.Unlock()
+	return student, nil
+}
+
+// drops the entries which can not be served anymore, called with the lock held
+func (d *Cached) sweep() {
+	if time.Since(d.lastSweep) < time.Hour {
+		return
+	}
+	d.lastSweep = time.Now()
+	for claim, verifiedAt := range d.verified {
+		if time.Since(verifiedAt) > staleFor {
+			delete(d.verified, claim)
+		}
+	}
+	for email, entry := range d.students {
+		if time.Since(entry.loadedAt) > staleFor {
+			delete(d.students, email)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package directory
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// counting passes the calls on to Fake, down makes every call unavailable
+type counting struct {
+	verifies int
+	lookups  int
+	down     bool
+}
+
+func (b *counting) Verify(claim Claim) error {
+	b.verifies++
+	if b.down {
+		return ErrUnavailable
+	}
+	return Fake{}.Verify(claim)
+}
+
+func (b *counting) Lookup(email string) (Student, error) {
+	b.lookups++
+	if b.down {
+		return Student{}, ErrUnavailable
+	}
+	return Fake{}.Lookup(email)
+}
+
+var testClaim = Claim{Email: "Student@iitk.ac.in", RollNo: "230001", Name: "Test  Student", Dept: "CSE", Course: "BT"}
+
+// age moves every entry of the cache back in time
+func age(d *Cached, by time.Duration) {
+	for claim, verifiedAt := range d.verified {
+		d.verified[claim] = verifiedAt.Add(-by)
+	}
+	for email, entry := range d.students {
+		entry.loadedAt = entry.loadedAt.Add(-by)
+		d.students[email] = entry
+	}
+}
+
+func TestCachedReusesWithinTTL(t *testing.T) {
+	backend := &counting{}
+	d := NewCached(backend, time.Hour)
+
+	if err := d.Verify(testClaim); err != nil {
+		t.Fatal(err)
+	}
+	// Case and whitespace do not make a new entry
+	if err := d.Verify(Claim{Email: "student@IITK.ac.in", RollNo: "230001", Name: "test student", Dept: "cse", Course: "bt"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Lookup("Student@iitk.ac.in"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Lookup("student@iitk.ac.in"); err != nil {
+		t.Fatal(err)
+	}
+	if backend.verifies != 1 || backend.lookups != 1 {
+		t.Fatalf("backend called %d verifies and %d lookups, want 1 and 1", backend.verifies, backend.lookups)
+	}
+
+	age(d, 2*time.Hour)
+	if err := d.Verify(testClaim); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Lookup("student@iitk.ac.in"); err != nil {
+		t.Fatal(err)
+	}
+	if backend.verifies != 2 || backend.lookups != 2 {
+		t.Fatalf("expired entries reused: %d verifies and %d lookups, want 2 and 2", backend.verifies, backend.lookups)
+	}
+}
+
+func TestCachedDoesNotKeepFailures(t *testing.T) {
+	backend := &counting{}
+	d := NewCached(backend, time.Hour)
+	claim := testClaim
+	claim.RollNo = "404"
+	for range 2 {
+		if err := d.Verify(claim); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Verify = %v, want ErrNotFound", err)
+		}
+	}
+	if backend.verifies != 2 {
+		t.Fatalf("backend called %d times, want 2", backend.verifies)
+	}
+}
+
+func TestCachedServesStaleWhenUnavailable(t *testing.T) {
+	backend := &counting{}
+	d := NewCached(backend, time.Hour)
+	if err := d.Verify(testClaim); err != nil {
+		t.Fatal(err)
+	}
+	want, err := d.Lookup("student@iitk.ac.in")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backend.down = true
+	age(d, 2*time.Hour)
+	if err := d.Verify(testClaim); err != nil {
+		t.Fatalf("stale Verify = %v, want nil", err)
+	}
+	if got, err := d.Lookup("student@iitk.ac.in"); err != nil || got != want {
+		t.Fatalf("stale Lookup = %+v, %v, want %+v", got, err, want)
+	}
+	// Never seen, nothing to fall back on
+	if _, err := d.Lookup("other@iitk.ac.in"); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("Lookup = %v, want ErrUnavailable", err)
+	}
+
+	age(d, staleFor)
+	if err := d.Verify(testClaim); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("Verify past staleFor = %v, want ErrUnavailable", err)
+	}
+	if _, err := d.Lookup("student@iitk.ac.in"); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("Lookup past staleFor = %v, want ErrUnavailable", err)
+	}
+}
+
+func TestCachedSweep(t *testing.T) {
+	d := NewCached(&counting{}, time.Hour)
+	if err := d.Verify(testClaim); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Lookup("student@iitk.ac.in"); err != nil {
+		t.Fatal(err)
+	}
+	age(d, staleFor+time.Hour)
+
+	// Sweeps run at most once an hour
+	if _, err := d.Lookup("fresh@iitk.ac.in"); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.verified) != 1 || len(d.students) != 2 {
+		t.Fatalf("swept too early: %d verified, %d students", len(d.verified), len(d.students))
+	}
+
+	d.lastSweep = time.Now().Add(-2 * time.Hour)
+	if _, err := d.Lookup("another@iitk.ac.in"); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.verified) != 0 {
+		t.Fatalf("%d verified claims left, want 0", len(d.verified))
+	}
+	if _, ok := d.students["student@iitk.ac.in"]; ok || len(d.students) != 2 {
+		t.Fatalf("students after the sweep: %v, want only the fresh ones", d.students)
+	}
+}
@@ -0,0 +1,88 @@
+package directory
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// CSV serves the records from a file exported by the institute, loaded once at start up.
+// The first row is the header, known columns: roll_no, name, email, program, department, gender, hostel_info, location
+type CSV struct {
+	byEmail map[string]Student
+}
+
+func NewCSV(path string) (*CSV, error) {
+	if path == "" {
+		return nil, fmt.Errorf("directory.csv is not configured")
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1 // the trailing empty columns are often cut off by the export
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%s is empty", path)
+	}
+	columns := map[string]int{}
+	for i, name := range rows[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["email"]; !ok {
+		return nil, fmt.Errorf("%s has no email column", path)
+	}
+
+	d := &CSV{byEmail: make(map[string]Student, len(rows)-1)}
+	for _, row := range rows[1:] {
+		get := func(column string) string {
+			if i, ok := columns[column]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+		email := strings.ToLower(get("email"))
+		if email == "" {
+			continue
+		}
+		username, _, _ := strings.Cut(email, "@")
+		d.byEmail[email] = Student{
+			RollNo:     get("roll_no"),
+			Name:       get("name"),
+			Email:      email,
+			Program:    get("program"),
+			Department: get("department"),
+			Gender:     get("gender"),
+			HostelInfo: get("hostel_info"),
+			Username:   username,
+			Location:   get("location"),
+		}
+	}
+	return d, nil
+}
+
+func (d *CSV) Lookup(email string) (Student, error) {
+	student, ok := d.byEmail[strings.ToLower(email)]
+	if !ok {
+		return Student{}, ErrNotFound
+	}
+	return student, nil
+}
+
+func (d *CSV) Verify(claim Claim) error {
+	student, err := d.Lookup(claim.Email)
+	if err != nil {
+		return err
+	}
+	if !matches(student, claim) {
+		return ErrMismatch
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package directory
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeCSV(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "students.csv")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCSV(t *testing.T) {
+	// Header in any case and order, unknown columns are ignored
+	path := writeCSV(t, ` Email ,Roll_No,NAME,department,program,extra
+Student@IITK.ac.in, 230001 ,Test Student,CSE,BT,x
+,230002,No Email,EE,BT,x
+short@iitk.ac.in,230003,Short Row
+`)
+	d, err := NewCSV(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	student, err := d.Lookup("student@iitk.ac.in")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Student{RollNo: "230001", Name: "Test Student", Email: "student@iitk.ac.in", Program: "BT", Department: "CSE", Username: "student"}
+	if student != want {
+		t.Fatalf("Lookup = %+v, want %+v", student, want)
+	}
+	if student, err := d.Lookup("SHORT@iitk.ac.in"); err != nil || student.Name != "Short Row" || student.Department != "" {
+		t.Fatalf("short row = %+v, %v", student, err)
+	}
+	if _, err := d.Lookup("missing@iitk.ac.in"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Lookup = %v, want ErrNotFound", err)
+	}
+	if len(d.byEmail) != 2 {
+		t.Fatalf("%d students loaded, want 2 (rows without an email are skipped)", len(d.byEmail))
+	}
+
+	tests := []struct {
+		name  string
+		claim Claim
+		want  error
+	}{
+		{"match", Claim{Email: "student@iitk.ac.in", RollNo: "230001", Name: "test  student", Dept: "cse", Course: "bt"}, nil},
+		{"wrong name", Claim{Email: "student@iitk.ac.in", RollNo: "230001", Name: "Other Student", Dept: "CSE", Course: "BT"}, ErrMismatch},
+		{"unknown email", Claim{Email: "missing@iitk.ac.in", RollNo: "230001", Name: "Test Student"}, ErrNotFound},
+		{"record without department", Claim{Email: "short@iitk.ac.in", RollNo: "230003", Name: "Short Row", Dept: "ME", Course: "BT"}, nil},
+	}
+	for _, tt := range tests {
+		if err := d.Verify(tt.claim); !errors.Is(err, tt.want) {
+			t.Errorf("%s: Verify = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestCSVInvalid(t *testing.T) {
+	if _, err := NewCSV(""); err == nil {
+		t.Error("no path: want an error")
+	}
+	if _, err := NewCSV(writeCSV(t, "")); err == nil {
+		t.Error("empty file: want an error")
+	}
+	if _, err := NewCSV(writeCSV(t, "roll_no,name\n230001,Test Student\n")); err == nil {
+		t.Error("no email column: want an error")
+	}
+}
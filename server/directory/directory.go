@@ -0,0 +1,129 @@
+// Student records of the institute, used to verify the profile details and to prefill the profile form.
+// The backends are picked from the config, use "fake" for dev and CI
+package directory
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+var (
+	ErrNotFound    = errors.New("student not found in the records")
+	ErrMismatch    = errors.New("details do not match the records")
+	ErrUnavailable = errors.New("student records are unavailable")
+	ErrUnsupported = errors.New("operation not supported by the backend")
+)
+
+// Student as known to the records, the json matches the old automation server response
+type Student struct {
+	RollNo     string `json:"roll_no"`
+	Name       string `json:"name"`
+	Email      string `json:"email,omitempty"`
+	Program    string `json:"program"`
+	Department string `json:"department"`
+	Gender     string `json:"gender"`
+	HostelInfo string `json:"hostel_info"`
+	Username   string `json:"username"`
+	Location   string `json:"location"`
+}
+
+// Claim is what the user filled in the profile form
+type Claim struct {
+	Email  string
+	RollNo string
+	Name   string
+	Dept   string
+	Course string
+}
+
+type StudentDirectory interface {
+	// Verify checks the claimed details against the records, nil when they match
+	Verify(claim Claim) error
+	// Lookup finds the student by the iitk email
+	Lookup(email string) (Student, error)
+}
+
+// New builds the backend by name: oa, automation, csv or fake
+func New(backend string) (StudentDirectory, error) {
+	switch backend {
+	case "oa":
+		return oa{url: viper.GetString("oa.url"), key: viper.GetString("oa.key")}, nil
+	case "automation":
+		return automation{url: viper.GetString("automation.url"), key: viper.GetString("automation.key")}, nil
+	case "csv":
+		return NewCSV(viper.GetString("directory.csv"))
+	case "fake":
+		return Fake{}, nil
+	}
+	return nil, fmt.Errorf("unknown student directory backend %q", backend)
+}
+
+// split sends the verification and the lookups to different backends,
+// OA can only verify and the automation server can only look up by email in prod
+type split struct {
+	verifier StudentDirectory
+	lookup   StudentDirectory
+}
+
+func (s split) Verify(claim Claim) error             { return s.verifier.Verify(claim) }
+func (s split) Lookup(email string) (Student, error) { return s.lookup.Lookup(email) }
+
+var directory StudentDirectory
+
+// Load builds the configured (and cached) directory, called once at startup after viper is loaded
+func Load() error {
+	verifyBackend := viper.GetString("directory.verify")
+	if verifyBackend == "" {
+		verifyBackend = "oa"
+	}
+	lookupBackend := viper.GetString("directory.lookup")
+	if lookupBackend == "" {
+		lookupBackend = "automation"
+	}
+	if lookupBackend == "oa" {
+		return errors.New("oa can not look up students, use automation, csv or fake")
+	}
+	verifier, err := New(verifyBackend)
+	if err != nil {
+		return err
+	}
+	lookup, err := New(lookupBackend)
+	if err != nil {
+		return err
+	}
+	ttl := viper.GetDuration("directory.cache_ttl")
+	if ttl == 0 {
+		ttl = 24 * time.Hour
+	}
+	directory = NewCached(split{verifier: verifier, lookup: lookup}, ttl)
+	return nil
+}
+
+// Default is the directory built by Load
+func Default() StudentDirectory {
+	return directory
+}
+
+// matches compares the claim with a record: roll number and name always,
+// department and course only when the record has them
+func matches(student Student, claim Claim) bool {
+	if normalize(student.RollNo) != normalize(claim.RollNo) || normalize(student.Name) != normalize(claim.Name) {
+		return false
+	}
+	if student.Department != "" && normalize(student.Department) != normalize(claim.Dept) {
+		return false
+	}
+	if student.Program != "" && normalize(student.Program) != normalize(claim.Course) {
+		return false
+	}
+	return true
+}
+
+// extra whitespaces and case do not matter
+func normalize(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
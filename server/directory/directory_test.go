@@ -0,0 +1,55 @@
+package directory
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestMatches(t *testing.T) {
+	student := Student{RollNo: "230001", Name: "Test Student", Department: "CSE", Program: "BT"}
+	tests := []struct {
+		name  string
+		claim Claim
+		want  bool
+	}{
+		{"exact", Claim{RollNo: "230001", Name: "Test Student", Dept: "CSE", Course: "BT"}, true},
+		{"case and spaces", Claim{RollNo: " 230001", Name: "  test\tSTUDENT ", Dept: "cse", Course: "bt "}, true},
+		{"roll number", Claim{RollNo: "230002", Name: "Test Student", Dept: "CSE", Course: "BT"}, false},
+		{"name", Claim{RollNo: "230001", Name: "Test Students", Dept: "CSE", Course: "BT"}, false},
+		{"spaces inside a word", Claim{RollNo: "230001", Name: "TestStudent", Dept: "CSE", Course: "BT"}, false},
+		{"department", Claim{RollNo: "230001", Name: "Test Student", Dept: "EE", Course: "BT"}, false},
+		{"course", Claim{RollNo: "230001", Name: "Test Student", Dept: "CSE", Course: "MT"}, false},
+	}
+	for _, tt := range tests {
+		if got := matches(student, tt.claim); got != tt.want {
+			t.Errorf("%s: matches = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+
+	// The department and course are only compared when the record has them
+	partial := Student{RollNo: "230001", Name: "Test Student"}
+	if !matches(partial, Claim{RollNo: "230001", Name: "Test Student", Dept: "EE", Course: "MT"}) {
+		t.Error("record without department and course: want a match")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	defer viper.Reset()
+	viper.Set("directory.verify", "fake")
+	viper.Set("directory.lookup", "oa")
+	if err := Load(); err == nil {
+		t.Error("oa lookups: want an error")
+	}
+	viper.Set("directory.lookup", "nope")
+	if err := Load(); err == nil {
+		t.Error("unknown backend: want an error")
+	}
+	viper.Set("directory.lookup", "fake")
+	if err := Load(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Default().Lookup("student@iitk.ac.in"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,43 @@
+package directory
+
+import (
+	"strings"
+)
+
+// Fake never leaves the machine, every claim passes and every email resolves to a student.
+// Special values trigger the failures: roll number "404" (not found), "503" (unavailable)
+// and the name "Mismatch". Only for dev and CI.
+type Fake struct{}
+
+func (Fake) Verify(claim Claim) error {
+	switch {
+	case claim.RollNo == "404":
+		return ErrNotFound
+	case claim.RollNo == "503":
+		return ErrUnavailable
+	case normalize(claim.Name) == "mismatch":
+		return ErrMismatch
+	}
+	return nil
+}
+
+func (Fake) Lookup(email string) (Student, error) {
+	username, _, _ := strings.Cut(strings.ToLower(email), "@")
+	switch username {
+	case "404":
+		return Student{}, ErrNotFound
+	case "503":
+		return Student{}, ErrUnavailable
+	}
+	return Student{
+		RollNo:     "230001",
+		Name:       username,
+		Email:      strings.ToLower(email),
+		Program:    "BT",
+		Department: "CSE",
+		Gender:     "M",
+		HostelInfo: "HALL1, A-101",
+		Username:   username,
+		Location:   "Kanpur",
+	}, nil
+}
@@ -0,0 +1,131 @@
+package directory
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// oa verifies through the OA api, it does not take the name as input but returns it upon verification.
+// We send the email and the details, OA answers true or false with the name of the student.
+type oa struct {
+	url string
+	key string
+}
+
+type oaResponse struct {
+	RollNumber *string `json:"rollNumber"`
+	Name       *string `json:"name"`
+	Email      *string `json:"email"`
+	Status     *string `json:"status"`
+	Timestamp  *string `json:"timestamp"`
+	Message    *string `json:"message"`
+}
+
+func (o oa) Verify(claim Claim) error {
+	paramkey := fmt.Sprintf("%s:%s:%s:%s", claim.RollNo, claim.Course, claim.Dept, claim.Email)
+	paramkey = url.QueryEscape(paramkey) // To ensure the paramkey for "(MSc 2yr)" and such cases is correctly parsed
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s?paramkey=%s", o.url, paramkey), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("x-api-key", o.key)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to call OA API")
+		return ErrUnavailable
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		// Send mail to maintainers
+		logrus.Errorf("OA Token expired or missing, Urgent action required, request new or check viper env")
+		return ErrUnavailable
+	case resp.StatusCode >= 500:
+		logrus.Error("OA API ERROR, with status code: ", resp.StatusCode)
+		return ErrUnavailable
+	case resp.StatusCode != http.StatusOK:
+		logrus.Error("OA API ERROR, with status code: ", resp.StatusCode)
+		return ErrMismatch
+	}
+
+	var apiResp oaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		logrus.WithError(err).Error("Failed to parse OA API response")
+		return ErrUnavailable
+	}
+	if apiResp.Status == nil {
+		return ErrNotFound
+	}
+	if *apiResp.Status != "true" || apiResp.Name == nil || normalize(*apiResp.Name) != normalize(claim.Name) {
+		return ErrMismatch
+	}
+	return nil
+}
+
+func (oa) Lookup(email string) (Student, error) {
+	return Student{}, ErrUnsupported
+}
+
+// automation server of the club, returns the details of a student by the email
+type automation struct {
+	url string
+	key string
+}
+
+func (a automation) Lookup(email string) (Student, error) {
+	if a.url == "" {
+		logrus.Error("automation.url is not configured")
+		return Student{}, ErrUnavailable
+	}
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/getDetails?email=%s", a.url, url.QueryEscape(email)), nil)
+	if err != nil {
+		return Student{}, err
+	}
+	req.Header.Set("x-api-key", a.key)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to call automation server")
+		return Student{}, ErrUnavailable
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return Student{}, ErrNotFound
+	default:
+		logrus.WithField("status", resp.StatusCode).Error("Automation server returned error")
+		return Student{}, ErrUnavailable
+	}
+
+	var student Student
+	if err := json.NewDecoder(resp.Body).Decode(&student); err != nil {
+		logrus.WithError(err).Error("Failed to parse automation server response")
+		return Student{}, ErrUnavailable
+	}
+	if student.Email == "" {
+		student.Email = email
+	}
+	return student, nil
+}
+
+func (a automation) Verify(claim Claim) error {
+	student, err := a.Lookup(claim.Email)
+	if err != nil {
+		return err
+	}
+	if !matches(student, claim) {
+		return ErrMismatch
+	}
+	return nil
+}
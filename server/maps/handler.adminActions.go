@@ -4,7 +4,15 @@ import (
 	"compass/assets"
 	"compass/connections"
 	"compass/model"
+	"compass/workers"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -75,89 +83,223 @@ func flagAction(c *gin.Context) {
 	}
 }
 
+// Approve, reject or send back a pending location. The contributor gets a mail in each case.
 func locationAction(c *gin.Context) {
-	// add the request model to the request.model.go file
-
-	// locationID := c.Param("id")
-
-	// var req RequestAddLocation
-	// if err := c.ShouldBindJSON(&req); err != nil {
-	// 	c.JSON(400, gin.H{"error": "Invalid request"})
-	// 	return
-	// }
-
-	// var loc RequestAddLocation
-	// if err := connections.DB.Model(&RequestAddLocation{}).Where("id = ?", locationID).First(&loc).Error; err != nil {
-	// 	c.JSON(404, gin.H{"error": "Location request not found"})
-	// 	return
-	// }
-
-	// // add the location in the database if user approve it, else reject it
-	// if req.Status == "approved" {
-	// 	// Insert into final Location table (assuming model.Location exists)
-	// 	final := model.Location{
-	// 		Name:      loc.Title,
-	// 		Latitude:  loc.Latitude,
-	// 		Longitude: loc.Longitude,
-	// 		// LocationType:  loc.LocationType, // no locationType in Location
-	// 		ContributedBy: loc.Contributor_id,
-	// 		Description:   loc.Description,
-	// 		// Image:         loc.Image, // no field for image in Location
-	// 		Status: "approved", //loc.status giving type error
-	// 	}
-	// 	if err := connections.DB.Create(&final).Error; err != nil {
-	// 		c.JSON(500, gin.H{"error": "Failed to add location"})
-	// 		return
-	// 	}
-
-	// 	loc.Status = "approved" // approving in og req table
-	// 	connections.DB.Save(&loc)
-
-	// 	// Send mail thanking contributor
-	// 	connections.MQChannel.Publish(
-	// 		"",
-	// 		viper.GetString("rabbitmq.mailqueue"),
-	// 		false,
-	// 		false,
-	// 		amqp.Publishing{
-	// 			ContentType: "application/json",
-	// 			Body:        []byte(`{"userId": "` + loc.Contributor_id.String() + `", "message": "Thanks for contributing a location! It's now live."}`),
-	// 		},
-	// 	)
-
-	// 	c.JSON(200, gin.H{"message": "Location approved and added"})
-	// 	return
-	// }
-
-	// if req.Status == "rejected" {
-	// 	if req.Message == "" {
-	// 		c.JSON(400, gin.H{"error": "Rejection message required"})
-	// 		return
-	// 	}
-
-	// 	loc.Status = "rejected"
-	// 	connections.DB.Save(&loc)
-
-	// 	// Send rejection mail
-	// 	connections.MQChannel.Publish(
-	// 		"",
-	// 		viper.GetString("rabbitmq.mailqueue"),
-	// 		false,
-	// 		false,
-	// 		amqp.Publishing{
-	// 			ContentType: "application/json",
-	// 			Body:        []byte(`{"userId": "` + loc.Contributor_id.String() + `", "message": "` + req.Message + `"}`),
-	// 		},
-	// 	)
-
-	// 	c.JSON(200, gin.H{"message": "Location rejected", "details": req.Message})
-	// 	return
-	// }
-	// c.JSON(400, gin.H{"error": "Invalid action"})
-
-	// // in both the cases notify the user with a mail, either thanking for contribution or saying sorry
-
-	// // Handle all the edge cases with suitable return http code, write them in the read me for later documentation
+	locationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid location id"})
+		return
+	}
+	var req LocationActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	req.Message = strings.TrimSpace(req.Message)
+	if req.Edits != nil {
+		if err := req.Edits.Normalize(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.Action != "approve" && req.Message == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A message for the contributor is required"})
+		return
+	}
+
+	var location model.Location
+	if err := connections.DB.Preload("User", func(db *gorm.DB) *gorm.DB { return db.Select("user_id", "email") }).
+		Preload("CoverPic").Preload("BioPics").
+		Where("location_id = ?", locationID).First(&location).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Location not found"})
+			return
+		}
+		logrus.Errorf("Failed to fetch the location %s: %v", locationID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the location"})
+		return
+	}
+	// A location sent back for changes may still be decided on
+	if location.Status != model.Pending && location.Status != model.ChangesRequested {
+		c.JSON(http.StatusConflict, gin.H{"error": "Location is already " + string(location.Status)})
+		return
+	}
+
+	actorID := c.MustGet("userID").(uuid.UUID)
+	entry := model.Logs{
+		ActionTaker: model.Role(c.GetInt("userRole")).BaseRoleName(),
+		ActorID:     &actorID,
+		TargetID:    &location.ContributedBy,
+	}
+	updates := map[string]interface{}{}
+	var outcome, response string
+	switch req.Action {
+	case "approve":
+		updates = req.Edits.Columns()
+		summary := make([]string, 0, len(updates))
+		for column, value := range updates {
+			summary = append(summary, fmt.Sprintf("%s=%v", column, value))
+		}
+		sort.Strings(summary)
+		updates["status"] = model.Approved
+		updates["review_note"] = ""
+		outcome, response = "approved", "Location approved"
+		entry.Title = "Location approved"
+		entry.Description = fmt.Sprintf("%s (%s)", location.Name, location.LocationId)
+		if len(summary) > 0 {
+			entry.Description += ", edited: " + strings.Join(summary, ", ")
+		}
+		if name, ok := updates["name"].(string); ok {
+			location.Name = name // the mail has the published name
+		}
+	case "reject":
+		updates["status"] = model.Rejected
+		updates["review_note"] = req.Message
+		outcome, response = "rejected", "Location rejected"
+		entry.Title = "Location rejected"
+		entry.Description = fmt.Sprintf("%s (%s): %s", location.Name, location.LocationId, req.Message)
+	case "request_changes":
+		updates["status"] = model.ChangesRequested
+		updates["review_note"] = req.Message
+		outcome, response = "changes_requested", "Changes requested from the contributor"
+		entry.Title = "Location changes requested"
+		entry.Description = fmt.Sprintf("%s (%s): %s", location.Name, location.LocationId, req.Message)
+	}
+
+	var approvedImages []uuid.UUID
+	if err := connections.DB.Transaction(func(tx *gorm.DB) error {
+		// The status is checked again, two admins may act on the same request
+		result := tx.Model(&model.Location{}).
+			Where("location_id = ? AND status = ?", location.LocationId, location.Status).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errLocationReviewed
+		}
+		if err := connections.AddLog(tx, entry); err != nil {
+			return err
+		}
+		if req.Action != "approve" {
+			return nil
+		}
+		var err error
+		approvedImages, err = approveLocationImages(tx, location)
+		return err
+	}); err != nil {
+		if errors.Is(err, errLocationReviewed) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Location was reviewed by someone else"})
+			return
+		}
+		logrus.Errorf("Failed to review the location %s: %v", location.LocationId, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to review the location"})
+		return
+	}
+
+	// The files are moved once the approval is committed, they can not be moved back on a rollback
+	var unpublished []uuid.UUID
+	if len(approvedImages) > 0 {
+		if unpublished = publishImages(approvedImages); len(unpublished) > 0 {
+			response += fmt.Sprintf(", %d images could not be published, retry from the location", len(unpublished))
+		}
+	}
+
+	if location.User != nil && location.User.Email != "" {
+		job := workers.MailJob{
+			Type: "location_review",
+			To:   location.User.Email,
+			Data: map[string]interface{}{
+				"outcome":  outcome,
+				"location": location.Name,
+				"message":  req.Message,
+			},
+		}
+		payload, _ := json.Marshal(job)
+		if err := workers.PublishJob(payload, model.MailQueue); err != nil {
+			logrus.Errorf("Failed to queue the location review mail for %s: %v", location.LocationId, err)
+		}
+	}
+	if len(unpublished) > 0 {
+		c.JSON(http.StatusOK, gin.H{"message": response, "unpublished": unpublished})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": response})
+}
+
+var errLocationReviewed = errors.New("location already reviewed")
+
+// Retry for the images of an approved location whose files could not be moved on the approval
+func publishLocationImages(c *gin.Context) {
+	locationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid location id"})
+		return
+	}
+	var location model.Location
+	if err := connections.DB.Select("location_id", "status").Where("location_id = ?", locationID).First(&location).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Location not found"})
+		return
+	}
+	if location.Status != model.Approved {
+		c.JSON(http.StatusConflict, gin.H{"error": "Location is " + string(location.Status) + ", its images are published on the approval"})
+		return
+	}
+	var images []uuid.UUID
+	if err := connections.DB.Model(&model.Image{}).
+		Where("parent_asset_id = ? AND parent_asset_type = ? AND status = ?", locationID, "locations", model.Approved).
+		Pluck("image_id", &images).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the images"})
+		return
+	}
+	if unpublished := publishImages(images); len(unpublished) > 0 {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("%d images could not be published", len(unpublished)), "unpublished": unpublished})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Images published"})
+}
+
+// approveLocationImages approves the images still waiting for the moderator, the admin has seen them with the request.
+// Images rejected by the moderator stay rejected. Returns the approved ones, their files are moved after the commit.
+func approveLocationImages(tx *gorm.DB, location model.Location) ([]uuid.UUID, error) {
+	images := location.BioPics
+	if location.CoverPic != nil {
+		images = append(images, *location.CoverPic)
+	}
+	// Both relations read the same polymorphic columns, the cover shows up among the bio pics too
+	seen := map[uuid.UUID]bool{}
+	var approved []uuid.UUID
+	for _, image := range images {
+		if image.Status != model.Pending || seen[image.ImageID] {
+			continue
+		}
+		seen[image.ImageID] = true
+		if err := tx.Model(&model.Image{}).Where("image_id = ?", image.ImageID).
+			Updates(map[string]interface{}{"status": model.Approved, "submitted": true}).Error; err != nil {
+			return nil, err
+		}
+		approved = append(approved, image.ImageID)
+	}
+	return approved, nil
+}
+
+// publishImages moves the files of approved images from tmp to public, it can be run again for the ones it returns.
+// Files already in public are skipped, the moderation worker may have published them in the meantime.
+func publishImages(images []uuid.UUID) (unpublished []uuid.UUID) {
+	published := func(imageID uuid.UUID) bool {
+		_, err := os.Stat(filepath.Join("./assets/public", imageID.String()+".webp"))
+		return err == nil
+	}
+	for _, imageID := range images {
+		if published(imageID) {
+			continue
+		}
+		if err := assets.MoveImageFromTmpToPublic(imageID); err != nil && !published(imageID) {
+			logrus.Errorf("Failed to publish the image %s: %v", imageID, err)
+			unpublished = append(unpublished, imageID)
+		}
+	}
+	return unpublished
 }
 
 func addNotice(c *gin.Context) {
@@ -4,13 +4,107 @@ import (
 	"compass/connections"
 	"compass/model"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
+const logsPerPage = 50
+
+// logFilter reads the common filters of the log apis: ?user=<id>&from=<RFC3339>&to=<RFC3339>&page=
+// user matches both the actor and the target. Answers 400 and returns false on a bad filter.
+func logFilter(c *gin.Context) (func(*gorm.DB) *gorm.DB, int, bool) {
+	var conditions []func(*gorm.DB) *gorm.DB
+	if user := c.Query("user"); user != "" {
+		userID, err := uuid.Parse(user)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user id"})
+			return nil, 0, false
+		}
+		conditions = append(conditions, func(db *gorm.DB) *gorm.DB {
+			return db.Where("actor_id = ? OR target_id = ?", userID, userID)
+		})
+	}
+	for param, operator := range map[string]string{"from": ">=", "to": "<"} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param + " time, use RFC3339"})
+			return nil, 0, false
+		}
+		conditions = append(conditions, func(db *gorm.DB) *gorm.DB {
+			return db.Where("created_at "+operator+" ?", at)
+		})
+	}
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	return func(db *gorm.DB) *gorm.DB {
+		for _, condition := range conditions {
+			db = condition(db)
+		}
+		return db
+	}, page, true
+}
+
+// System logs (admin actions, lockouts), newest first
 func systemLogsProvider(c *gin.Context) {
-	// static data, provide the logs, try to modify the route such that you enable pagination
+	filter, page, ok := logFilter(c)
+	if !ok {
+		return
+	}
+	var total int64
+	var logs []model.Logs
+	// Session, the query is used for both the count and the page
+	query := connections.DB.Model(&model.Logs{}).Scopes(filter).Session(&gorm.Session{})
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch logs"})
+		return
+	}
+	if err := query.Order("created_at DESC").Offset((page - 1) * logsPerPage).Limit(logsPerPage).Find(&logs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch logs"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"logs": logs, "total": total, "current_page": page, "per_page": logsPerPage})
+}
 
+// Auth events (logins, otp, resets, visibility, deletions), newest first.
+// Filters of systemLogsProvider, plus ?type=<event type>&outcome=success|failure
+func authEventsProvider(c *gin.Context) {
+	filter, page, ok := logFilter(c)
+	if !ok {
+		return
+	}
+	query := connections.DB.Model(&model.AuthEvent{}).Scopes(filter)
+	if eventType := c.Query("type"); eventType != "" {
+		query = query.Where("type = ?", eventType)
+	}
+	if outcome := c.Query("outcome"); outcome != "" {
+		if outcome != model.OutcomeSuccess && outcome != model.OutcomeFailure {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid outcome"})
+			return
+		}
+		query = query.Where("outcome = ?", outcome)
+	}
+	query = query.Session(&gorm.Session{})
+	var total int64
+	var events []model.AuthEvent
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch events"})
+		return
+	}
+	if err := query.Order("created_at DESC").Offset((page - 1) * logsPerPage).Limit(logsPerPage).Find(&events).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch events"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"events": events, "total": total, "current_page": page, "per_page": logsPerPage})
 }
 
 func flaggedReviewsProvider(c *gin.Context) {
@@ -39,12 +133,13 @@ func locationRequestProvider(c *gin.Context) {
 	// filter the pending location requests
 	var pending []model.Location
 
-	// Here only admin can accept or reject so just check pending
+	// Here only admin can accept or reject so just check pending.
+	// The ones sent back for changes are listed too, they may still be decided on while the contributor is away.
 	err := connections.DB.
 		Model(&model.Location{}).
 		Preload("User", connections.UserSelect).
 		Preload("CoverPic", connections.ImageSelect).
-		Where("status = ?", "pending").
+		Where("status IN ?", []model.Status{model.Pending, model.ChangesRequested}).
 		Find(&pending).Error
 
 	if err != nil {
@@ -0,0 +1,71 @@
+package maps
+
+import (
+	"compass/connections"
+	"compass/model"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Saved locations of the user, most recent first
+func bookmarksProvider(c *gin.Context) {
+	var bookmarks []model.Bookmark
+	if err := connections.DB.
+		Preload("Location", func(db *gorm.DB) *gorm.DB {
+			return db.Select("location_id", "name", "description", "latitude", "longitude", "location_type")
+		}).
+		Where("user_id = ?", c.MustGet("userID").(uuid.UUID)).
+		Order("created_at DESC").
+		Find(&bookmarks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bookmarks"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"bookmarks": bookmarks})
+}
+
+func addBookmark(c *gin.Context) {
+	locationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid location ID"})
+		return
+	}
+	// Only the approved locations are visible, so only they can be saved
+	var count int64
+	if err := connections.DB.Model(&model.Location{}).
+		Where("location_id = ? AND status = ?", locationID, model.Approved).
+		Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if count == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Location not found"})
+		return
+	}
+	if err := connections.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.Bookmark{
+		UserID:     c.MustGet("userID").(uuid.UUID),
+		LocationId: locationID,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add bookmark"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Bookmark added"})
+}
+
+func removeBookmark(c *gin.Context) {
+	locationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid location ID"})
+		return
+	}
+	if err := connections.DB.
+		Where("user_id = ? AND location_id = ?", c.MustGet("userID").(uuid.UUID), locationID).
+		Delete(&model.Bookmark{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove bookmark"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Bookmark removed"})
+}
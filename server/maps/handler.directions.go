@@ -0,0 +1,99 @@
+package maps
+
+import (
+	"compass/connections"
+	"compass/model"
+	"compass/routing"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Route between ?from=&to=, each one is "lat,lng" or the id of an approved location.
+// Optional: mode (walk / cycle), walk by default
+func directionsProvider(c *gin.Context) {
+	mode, err := routing.ParseMode(c.Query("mode"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	from, err := resolvePoint(c.Query("from"))
+	if err != nil {
+		pointError(c, "from", err)
+		return
+	}
+	to, err := resolvePoint(c.Query("to"))
+	if err != nil {
+		pointError(c, "to", err)
+		return
+	}
+
+	graph, err := routing.Default()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Directions are not available right now"})
+		return
+	}
+	route, err := graph.Route(from, to, mode)
+	switch {
+	case errors.Is(err, routing.ErrOffNetwork):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The points must be on campus"})
+		return
+	case errors.Is(err, routing.ErrNoRoute):
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("No %s route between the points", mode)})
+		return
+	case err != nil:
+		logrus.Errorf("Failed to find the route: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find the route"})
+		return
+	}
+	c.JSON(http.StatusOK, route)
+}
+
+// errLocationLookup is a database failure while resolving a point, not a fault of the request
+var errLocationLookup = errors.New("failed to fetch the location")
+
+func pointError(c *gin.Context, param string, err error) {
+	if errors.Is(err, errLocationLookup) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the location"})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": param + ": " + err.Error()})
+}
+
+// resolvePoint parses "lat,lng", anything else is taken as a location id
+func resolvePoint(s string) (routing.Point, error) {
+	if s == "" {
+		return routing.Point{}, errors.New("is required")
+	}
+	if latStr, lngStr, ok := strings.Cut(s, ","); ok {
+		lat, errLat := strconv.ParseFloat(strings.TrimSpace(latStr), 64)
+		lng, errLng := strconv.ParseFloat(strings.TrimSpace(lngStr), 64)
+		if errLat != nil || errLng != nil || lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+			return routing.Point{}, errors.New("invalid coordinates, use lat,lng")
+		}
+		return routing.Point{Lat: lat, Lng: lng}, nil
+	}
+
+	id, err := uuid.Parse(s)
+	if err != nil {
+		return routing.Point{}, errors.New("must be lat,lng or a location id")
+	}
+	var location model.Location
+	if err := connections.DB.Select("latitude", "longitude").
+		Where("location_id = ? AND status = ?", id, model.Approved).
+		First(&location).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return routing.Point{}, errors.New("location not found")
+		}
+		logrus.Errorf("Failed to fetch the location %s: %v", id, err)
+		return routing.Point{}, fmt.Errorf("%w %s: %v", errLocationLookup, id, err)
+	}
+	return routing.Point{Lat: float64(location.Latitude), Lng: float64(location.Longitude)}, nil
+}
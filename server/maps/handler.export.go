@@ -0,0 +1,171 @@
+package maps
+
+import (
+	"compass/connections"
+	"compass/model"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Row of the export, the cover is the first approved image of the location
+type exportLocation struct {
+	LocationId    uuid.UUID
+	Name          string
+	Description   string
+	Latitude      float32
+	Longitude     float32
+	LocationType  string
+	Tag           string
+	Contact       string
+	Time          string
+	AverageRating float32
+	ReviewCount   int64
+	UpdatedAt     time.Time
+	CoverImageId  *uuid.UUID
+}
+
+const coverImageSQL = `(SELECT image_id FROM images
+	WHERE parent_asset_id = locations.location_id AND parent_asset_type = 'locations' AND status = 'approved' AND deleted_at IS NULL
+	ORDER BY created_at LIMIT 1) AS cover_image_id`
+
+// Approved locations as a file for GIS tools, ?format=geojson|kml|gpx (geojson by default).
+// Optional: type, tag, bbox=minLng,minLat,maxLng,maxLat
+func locationExportProvider(c *gin.Context) {
+	format, ok := exportFormats[c.DefaultQuery("format", "geojson")]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be geojson, kml or gpx"})
+		return
+	}
+	query := connections.DB.Model(&model.Location{}).Where("status = ?", model.Approved)
+	if locationType := c.Query("type"); locationType != "" {
+		query = query.Where("location_type = ?", locationType)
+	}
+	if tag := c.Query("tag"); tag != "" {
+		query = query.Where("tag = ?", tag)
+	}
+	if bbox := c.Query("bbox"); bbox != "" {
+		box, err := parseBBox(bbox)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		query = query.Where("longitude BETWEEN ? AND ? AND latitude BETWEEN ? AND ?", box[0], box[2], box[1], box[3])
+	}
+	// Session, the query is used for both the ETag and the rows
+	query = query.Session(&gorm.Session{})
+
+	// The latest update changes on every edit, the count on a delete or a rejection
+	var version struct {
+		Count  int64
+		Latest *time.Time
+	}
+	if err := query.Select("COUNT(*) AS count, MAX(updated_at) AS latest").Scan(&version).Error; err != nil {
+		logrus.Errorf("Failed to fetch the export version: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export locations"})
+		return
+	}
+	// The cover is an image of the location, approving or deleting one changes only the images.
+	// Unscoped with the deletion time, a soft delete does not touch updated_at.
+	var images struct {
+		Count   int64
+		Deleted int64
+		Latest  *time.Time
+	}
+	if err := connections.DB.Unscoped().Model(&model.Image{}).
+		Select("COUNT(*) AS count, COUNT(deleted_at) AS deleted, MAX(GREATEST(updated_at, deleted_at)) AS latest").
+		Where("parent_asset_type = ? AND parent_asset_id IN (?)", "locations", query.Select("location_id")).
+		Scan(&images).Error; err != nil {
+		logrus.Errorf("Failed to fetch the export version: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export locations"})
+		return
+	}
+	unixNano := func(t *time.Time) int64 {
+		if t == nil {
+			return 0
+		}
+		return t.UnixNano()
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%d|%d|%d|%d|%d", c.Request.URL.RawQuery, version.Count, unixNano(version.Latest),
+		images.Count, images.Deleted, unixNano(images.Latest))))
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "no-cache") // always revalidate, it is cheap with the ETag
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	rows, err := query.Select(`location_id, name, description, latitude, longitude, location_type, tag, contact, "time", average_rating, review_count, updated_at, ` + coverImageSQL).
+		Order("name").Rows()
+	if err != nil {
+		logrus.Errorf("Failed to fetch the locations for the export: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export locations"})
+		return
+	}
+	defer rows.Close()
+
+	c.Header("Content-Type", format.contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="compass-locations.%s"`, format.extension))
+	c.Status(http.StatusOK)
+	// Streamed row by row, the headers are sent so a failure can only cut the file short
+	encoder := format.encoder(c.Writer)
+	if err := encoder.begin(); err != nil {
+		return
+	}
+	for rows.Next() {
+		var location exportLocation
+		if err := connections.DB.ScanRows(rows, &location); err != nil {
+			logrus.Errorf("Failed to read a location for the export: %v", err)
+			return
+		}
+		if err := encoder.location(location); err != nil {
+			return // client went away
+		}
+	}
+	if err := rows.Err(); err != nil {
+		logrus.Errorf("Failed to read the locations for the export: %v", err)
+		return
+	}
+	encoder.end()
+}
+
+// parseBBox reads minLng,minLat,maxLng,maxLat, the GeoJSON bbox order
+func parseBBox(s string) ([4]float64, error) {
+	var box [4]float64
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return box, fmt.Errorf("bbox must be minLng,minLat,maxLng,maxLat")
+	}
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return box, fmt.Errorf("bbox must be minLng,minLat,maxLng,maxLat")
+		}
+		box[i] = v
+	}
+	if box[0] > box[2] || box[1] > box[3] || box[1] < -90 || box[3] > 90 || box[0] < -180 || box[2] > 180 {
+		return box, fmt.Errorf("bbox is out of range")
+	}
+	return box, nil
+}
+
+// etagMatches handles the list form of If-None-Match and *, weak tags compare equal
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,254 @@
+package maps
+
+import (
+	"compass/connections"
+	"compass/model"
+	"compass/workers"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const editsPerPage = 20
+
+var (
+	errEditReviewed = errors.New("edit already reviewed")
+	errEditOutdated = errors.New("edit changes nothing")
+)
+
+// A user proposes changes to an approved location, only the fields differing from the current row are kept
+func proposeLocationEdit(c *gin.Context) {
+	locationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid location id"})
+		return
+	}
+	var req LocationEditRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if err := req.Changes.Normalize(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	var location model.Location
+	if err := connections.DB.Where("location_id = ? AND status = ?", locationID, model.Approved).First(&location).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Location not found"})
+		return
+	}
+	// Only the changed fields are kept, an unchanged one would be written back over a later edit
+	changes := req.Changes.Changed(location)
+	if len(changes.Columns()) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing to change"})
+		return
+	}
+	// One open proposal per user and location, they may add to it once it is reviewed
+	var open int64
+	if err := connections.DB.Model(&model.LocationEdit{}).
+		Where("location_id = ? AND contributed_by = ? AND status = ?", locationID, userID, model.Pending).
+		Count(&open).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit the edit"})
+		return
+	}
+	if open > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "You already have an edit waiting for review on this location"})
+		return
+	}
+
+	edit := model.LocationEdit{
+		LocationId:    locationID,
+		ContributedBy: userID,
+		Changes:       changes,
+		Note:          strings.TrimSpace(req.Note),
+		Status:        model.Pending,
+	}
+	if err := connections.DB.Create(&edit).Error; err != nil {
+		logrus.Errorf("Failed to save the edit of %s: %v", locationID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit the edit"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Edit submitted for review", "edit": edit})
+}
+
+// Applied edits of the location, the latest first
+func locationHistoryProvider(c *gin.Context) {
+	locationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid location id"})
+		return
+	}
+	var versions []model.LocationVersion
+	if err := connections.DB.Where("location_id = ?", locationID).Order("version DESC").Find(&versions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the history"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"history": versions})
+}
+
+type pendingEdit struct {
+	model.LocationEdit
+	Diff []model.FieldDiff `json:"diff"` // against the current row, it may have changed since the proposal
+}
+
+// Review queue of the edits, oldest first, each one with its field by field diff
+func locationEditsProvider(c *gin.Context) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	query := connections.DB.Model(&model.LocationEdit{}).Where("status = ?", model.Pending).Session(&gorm.Session{})
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the edits"})
+		return
+	}
+	var edits []model.LocationEdit
+	if err := query.Preload("Location").
+		Order("created_at").Offset((page - 1) * editsPerPage).Limit(editsPerPage).
+		Find(&edits).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the edits"})
+		return
+	}
+	pending := make([]pendingEdit, 0, len(edits))
+	for _, edit := range edits {
+		entry := pendingEdit{LocationEdit: edit}
+		if edit.Location != nil {
+			entry.Diff = edit.Changes.Diff(*edit.Location)
+		}
+		pending = append(pending, entry)
+	}
+	c.JSON(http.StatusOK, gin.H{"edits": pending, "total": total, "current_page": page, "per_page": editsPerPage})
+}
+
+// Accept or reject an edit. Accepting applies it and records the version in one transaction.
+func editAction(c *gin.Context) {
+	editID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid edit id"})
+		return
+	}
+	var req EditActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	req.Message = strings.TrimSpace(req.Message)
+	if req.Action == "reject" && req.Message == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A message for the contributor is required"})
+		return
+	}
+	actorID := c.MustGet("userID").(uuid.UUID)
+	actorRole := model.Role(c.GetInt("userRole")).BaseRoleName()
+
+	var edit model.LocationEdit
+	var location model.Location
+	err = connections.DB.Transaction(func(tx *gorm.DB) error {
+		// Locked, two admins may act on the same edit, or on two edits of one location
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", editID).First(&edit).Error; err != nil {
+			return err
+		}
+		if edit.Status != model.Pending {
+			return errEditReviewed
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("location_id = ?", edit.LocationId).First(&location).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		review := map[string]interface{}{"reviewed_by": actorID, "review_note": req.Message, "reviewed_at": now}
+		entry := model.Logs{ActionTaker: actorRole, ActorID: &actorID, TargetID: &edit.ContributedBy}
+
+		if req.Action == "reject" {
+			review["status"] = model.Rejected
+			entry.Title = "Location edit rejected"
+			entry.Description = fmt.Sprintf("%s (%s), edit %s: %s", location.Name, location.LocationId, edit.ID, req.Message)
+		} else {
+			// Against the locked row, fields set since the proposal by another edit are left alone
+			diff := edit.Changes.Diff(location)
+			if len(diff) == 0 {
+				return errEditOutdated
+			}
+			changes := edit.Changes.Changed(location)
+			var latest struct{ Version int }
+			if err := tx.Model(&model.LocationVersion{}).Select("COALESCE(MAX(version), 0) AS version").
+				Where("location_id = ?", location.LocationId).Scan(&latest).Error; err != nil {
+				return err
+			}
+			version := model.LocationVersion{
+				LocationId: location.LocationId,
+				Version:    latest.Version + 1,
+				EditID:     &edit.ID,
+				EditedBy:   edit.ContributedBy,
+				ApprovedBy: actorID,
+				Before:     changes.Previous(location),
+				After:      changes,
+			}
+			if err := tx.Create(&version).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&model.Location{}).Where("location_id = ?", location.LocationId).
+				Updates(changes.Columns()).Error; err != nil {
+				return err
+			}
+			review["status"] = model.Approved
+			fields := make([]string, 0, len(diff))
+			for _, d := range diff {
+				fields = append(fields, fmt.Sprintf("%s: %v -> %v", d.Field, d.Current, d.Proposed))
+			}
+			entry.Title = "Location edit applied"
+			entry.Description = fmt.Sprintf("%s (%s) version %d, %s", location.Name, location.LocationId, version.Version, strings.Join(fields, ", "))
+		}
+		if err := tx.Model(&model.LocationEdit{}).Where("id = ?", edit.ID).Updates(review).Error; err != nil {
+			return err
+		}
+		return connections.AddLog(tx, entry)
+	})
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Edit not found"})
+		return
+	case errors.Is(err, errEditReviewed):
+		c.JSON(http.StatusConflict, gin.H{"error": "Edit is already " + string(edit.Status)})
+		return
+	case errors.Is(err, errEditOutdated):
+		c.JSON(http.StatusConflict, gin.H{"error": "The location already has these values, reject the edit instead"})
+		return
+	case err != nil:
+		logrus.Errorf("Failed to review the edit %s: %v", editID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to review the edit"})
+		return
+	}
+
+	if req.Action == "approve" {
+		var contributor model.User
+		if err := connections.DB.Select("user_id", "email").Where("user_id = ?", edit.ContributedBy).First(&contributor).Error; err == nil {
+			job := workers.MailJob{
+				Type: "thanks_contribution",
+				To:   contributor.Email,
+				Data: map[string]interface{}{
+					"username":      contributor.Email,
+					"content_title": "Your edit to " + location.Name,
+				},
+			}
+			payload, _ := json.Marshal(job)
+			if err := workers.PublishJob(payload, model.MailQueue); err != nil {
+				logrus.Errorf("Failed to queue the thanks mail for the edit %s: %v", edit.ID, err)
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Edit applied"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Edit rejected"})
+}
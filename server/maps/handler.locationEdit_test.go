@@ -0,0 +1,124 @@
+//go:build integration
+
+// Needs the postgres and rabbitmq of docker-compose: go test -tags integration ./maps
+package maps
+
+import (
+	"compass/connections"
+	"compass/model"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func createTestUser(t *testing.T, role model.Role) model.User {
+	t.Helper()
+	user := model.User{Email: "edit-" + uuid.NewString()[:8] + "@iitk.ac.in", IsVerified: true, Role: role}
+	if err := connections.DB.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	t.Cleanup(func() {
+		connections.DB.Unscoped().Where("user_id = ?", user.UserID).Delete(&model.Profile{})
+		connections.DB.Unscoped().Where("user_id = ?", user.UserID).Delete(&model.User{})
+	})
+	return user
+}
+
+// as calls the handler as the user, the route permissions are not under test here
+func as(t *testing.T, user model.User, handler gin.HandlerFunc, method, path, pattern, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := gin.New()
+	r.Handle(method, pattern, func(c *gin.Context) {
+		c.Set("userID", user.UserID)
+		c.Set("userRole", int(user.Role))
+	}, handler)
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func proposeEdit(t *testing.T, user model.User, locationID uuid.UUID, changes string) uuid.UUID {
+	t.Helper()
+	w := as(t, user, proposeLocationEdit, http.MethodPost, "/location/"+locationID.String()+"/edits", "/location/:id/edits", `{"changes":`+changes+`}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("propose %s: %d %s", changes, w.Code, w.Body)
+	}
+	var resp struct {
+		Edit model.LocationEdit `json:"edit"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.Edit.ID
+}
+
+func approveEdit(t *testing.T, admin model.User, editID uuid.UUID) {
+	t.Helper()
+	w := as(t, admin, editAction, http.MethodPost, "/edits/"+editID.String(), "/edits/:id", `{"action":"approve"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("approve %s: %d %s", editID, w.Code, w.Body)
+	}
+}
+
+func TestApproveStaleEditKeepsLaterEdits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	first, second := createTestUser(t, model.UserRole), createTestUser(t, model.UserRole)
+	admin := createTestUser(t, model.AdminRole)
+
+	location := model.Location{Name: "Edit Test Canteen", Latitude: 26.51, Longitude: 80.23, Time: "9-5", Status: model.Approved, ContributedBy: first.UserID}
+	if err := connections.DB.Create(&location).Error; err != nil {
+		t.Fatalf("create location: %v", err)
+	}
+	t.Cleanup(func() {
+		connections.DB.Unscoped().Where("location_id = ?", location.LocationId).Delete(&model.Location{})
+	})
+
+	// The form sends every field, the time is unchanged in the first proposal
+	stale := proposeEdit(t, first, location.LocationId, `{"name":"  Edit Test Cafe ","time":"9-5"}`)
+	overlapping := proposeEdit(t, second, location.LocationId, `{"time":"10-6"}`)
+	approveEdit(t, admin, overlapping)
+	approveEdit(t, admin, stale)
+
+	var got model.Location
+	if err := connections.DB.Where("location_id = ?", location.LocationId).First(&got).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "Edit Test Cafe" || got.Time != "10-6" {
+		t.Fatalf("location = %q, %q, want the new name and the time of the later edit", got.Name, got.Time)
+	}
+
+	var version model.LocationVersion
+	if err := connections.DB.Where("edit_id = ?", stale).First(&version).Error; err != nil {
+		t.Fatal(err)
+	}
+	if version.Version != 2 || version.After.Time != nil || version.Before.Time != nil ||
+		version.After.Name == nil || *version.After.Name != "Edit Test Cafe" || *version.Before.Name != "Edit Test Canteen" {
+		t.Fatalf("version %d: before %+v after %+v, want only the name", version.Version, version.Before, version.After)
+	}
+}
+
+func TestProposeEditRejectsBlankFields(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	user := createTestUser(t, model.UserRole)
+	location := model.Location{Name: "Blank Test Hall", Latitude: 26.51, Longitude: 80.23, Status: model.Approved, ContributedBy: user.UserID}
+	if err := connections.DB.Create(&location).Error; err != nil {
+		t.Fatalf("create location: %v", err)
+	}
+	t.Cleanup(func() {
+		connections.DB.Unscoped().Where("location_id = ?", location.LocationId).Delete(&model.Location{})
+	})
+
+	for _, changes := range []string{`{"name":""}`, `{"name":"   "}`, `{"contact":" "}`, `{"name":"Blank Test Hall"}`} {
+		w := as(t, user, proposeLocationEdit, http.MethodPost, "/location/"+location.LocationId.String()+"/edits", "/location/:id/edits", `{"changes":`+changes+`}`)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("propose %s: %d, want 400", changes, w.Code)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package maps
+
+import (
+	"compass/connections"
+	"compass/model"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	earthRadius         = 6371000.0 // metres
+	metresPerDegree     = 111320.0  // of latitude, and of longitude at the equator
+	defaultNearbyRadius = 500.0
+	maxNearbyRadius     = 5000.0 // the whole campus fits in it
+	nearbyPerPage       = 20
+)
+
+// Haversine distance in metres from the point, args: earthRadius, lat, lat, lng. Computed by postgres without PostGIS
+const distanceSQL = `2 * ? * ASIN(SQRT(
+	POWER(SIN(RADIANS(latitude - ?) / 2), 2) +
+	COS(RADIANS(?)) * COS(RADIANS(latitude)) * POWER(SIN(RADIANS(longitude - ?) / 2), 2)))`
+
+type nearbyLocation struct {
+	LocationId    uuid.UUID `json:"locationId"`
+	Name          string    `json:"name"`
+	Latitude      float32   `json:"latitude"`
+	Longitude     float32   `json:"longitude"`
+	LocationType  string    `json:"locationType"`
+	Tag           string    `json:"tag"`
+	AverageRating float32   `json:"avgRating"`
+	ReviewCount   int64     `json:"reviewCount"`
+	Distance      float64   `json:"distance"` // metres
+}
+
+// Approved locations around ?lat=&lng=, closest first.
+// Optional: radius (metres), type, tag, minRating, page
+func nearbyLocationsProvider(c *gin.Context) {
+	lat, errLat := strconv.ParseFloat(c.Query("lat"), 64)
+	lng, errLng := strconv.ParseFloat(c.Query("lng"), 64)
+	if errLat != nil || errLng != nil || lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Valid lat and lng are required"})
+		return
+	}
+	radius := defaultNearbyRadius
+	if r := c.Query("radius"); r != "" {
+		parsed, err := strconv.ParseFloat(r, 64)
+		if err != nil || parsed <= 0 || parsed > maxNearbyRadius {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "radius must be between 0 and 5000 metres"})
+			return
+		}
+		radius = parsed
+	}
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	// Bounding box of the circle, lets postgres use idx_location_lat_lng before computing any distance
+	dLat := radius / metresPerDegree
+	minLat, maxLat := lat-dLat, lat+dLat
+	minLng, maxLng := -180.0, 180.0
+	if cos := math.Cos(lat * math.Pi / 180); cos > 1e-6 {
+		dLng := radius / (metresPerDegree * cos)
+		minLng, maxLng = lng-dLng, lng+dLng
+	}
+
+	inner := connections.DB.Model(&model.Location{}).
+		Select("location_id, name, latitude, longitude, location_type, tag, average_rating, review_count, "+distanceSQL+" AS distance", earthRadius, lat, lat, lng).
+		Where("status = ?", model.Approved).
+		Where("latitude BETWEEN ? AND ? AND longitude BETWEEN ? AND ?", minLat, maxLat, minLng, maxLng)
+	if locationType := c.Query("type"); locationType != "" {
+		inner = inner.Where("location_type = ?", locationType)
+	}
+	if tag := c.Query("tag"); tag != "" {
+		inner = inner.Where("tag = ?", tag)
+	}
+	if r := c.Query("minRating"); r != "" {
+		minRating, err := strconv.ParseFloat(r, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid minRating"})
+			return
+		}
+		inner = inner.Where("average_rating >= ?", minRating)
+	}
+
+	// The box has corners outside the circle. Session, the query is used for both the count and the page
+	query := connections.DB.Table("(?) AS nearby", inner).Where("distance <= ?", radius).Session(&gorm.Session{})
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch locations"})
+		return
+	}
+	var locations []nearbyLocation
+	if err := query.Order("distance").Offset((page - 1) * nearbyPerPage).Limit(nearbyPerPage).Scan(&locations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch locations"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"locations": locations, "total": total, "current_page": page, "per_page": nearbyPerPage})
+}
@@ -150,3 +150,39 @@ func requestLocationAddition(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"message": "Location request submitted for review"})
 	}
 }
+
+// The contributor applies the changes an admin asked for and sends the location back to the review queue
+func resubmitLocation(c *gin.Context) {
+	locationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid location id"})
+		return
+	}
+	var req LocationResubmitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	if err := req.Changes.Normalize(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	// The review note stays, the admin sees what was asked along with the answer
+	updates := req.Changes.Columns()
+	updates["status"] = model.Pending
+	result := connections.DB.Model(&model.Location{}).
+		Where("location_id = ? AND contributed_by = ? AND status = ?", locationID, userID, model.ChangesRequested).
+		Updates(updates)
+	if result.Error != nil {
+		logrus.Errorf("Failed to resubmit the location %s: %v", locationID, result.Error)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to resubmit the location"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No location of yours is waiting for changes with this id"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Location resubmitted for review"})
+}
@@ -62,6 +62,29 @@ func (r AddReviewRequest) ToReview(userID uuid.UUID) model.Review {
 	}
 }
 
+// Review of a pending location by the admin, the message is required unless approving
+type LocationActionRequest struct {
+	Action  string                 `json:"action" binding:"required,oneof=approve reject request_changes"`
+	Message string                 `json:"message" binding:"max=1000"`
+	Edits   *model.LocationChanges `json:"edits"` // corrections of the admin, applied on approval only
+}
+
+// Edit proposed by a user for an approved location
+type LocationEditRequest struct {
+	Changes model.LocationChanges `json:"changes" binding:"required"`
+	Note    string                `json:"note" binding:"max=500"`
+}
+
+// Contributor's answer to the changes requested by an admin, the changes may be empty when nothing needed fixing
+type LocationResubmitRequest struct {
+	Changes model.LocationChanges `json:"changes"`
+}
+
+type EditActionRequest struct {
+	Action  string `json:"action" binding:"required,oneof=approve reject"`
+	Message string `json:"message" binding:"max=1000"`
+}
+
 type FlagActionRequest struct {
 	Action  string `json:"action" binding:"required,oneof=approved rejected"`
 	Message string `json:"message"`
@@ -3,6 +3,7 @@ package maps
 
 import (
 	"compass/middleware"
+	"compass/model"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,7 +17,11 @@ func Router(r *gin.Engine) {
 		maps.GET("/notice", noticeProvider)         // each page will provide 10 notices (all the details about the notices)
 		maps.GET("/notice/:id", noticeDetailProvider)
 		maps.GET("/location/:id", locationDetailProvider) // provide exact details about the location using the id
+		maps.GET("/location/:id/history", locationHistoryProvider) // applied edits, latest first
 		maps.GET("/locations/incremental", incrementalLocationProvider) // incremental location updates
+		maps.GET("/locations/nearby", nearbyLocationsProvider)          // ?lat=&lng=&radius=&type=&tag=&minRating=&page=, closest first
+		maps.GET("/locations/export", locationExportProvider)           // ?format=geojson|kml|gpx&type=&tag=&bbox=, ETag on the latest update
+		maps.GET("/directions", directionsProvider)                     // ?from=&to=&mode=, from and to are lat,lng or a location id
 		maps.GET("/reviews/:id/:page", reviewProvider)    // provide the reviews of the location id, most recent 50, if there are more do the pagination
         maps.GET("/location/fuzzy", FuzzySearchLocationsHandler)
         maps.GET("/notice/fuzzy", FuzzySearchNoticesHandler)
@@ -24,24 +29,34 @@ func Router(r *gin.Engine) {
 		// User-protected routes
 		user := maps.Group("/")
 		user.Use(middleware.UserAuthenticator, middleware.EmailVerified)
-		user.POST("/review", addReview)                 // add a review in the rabbit mq queue for processing
-		user.POST("/location", requestLocationAddition) // add a location request in the table
+		user.POST("/review", middleware.Require(model.PermReviewCreate), addReview)                   // add a review in the rabbit mq queue for processing
+		user.POST("/location", middleware.Require(model.PermLocationContribute), requestLocationAddition) // add a location request in the table
+		user.POST("/location/:id/edits", middleware.Require(model.PermLocationContribute), proposeLocationEdit) // only the changed fields, reviewed by admin
+		user.POST("/location/:id/resubmit", middleware.Require(model.PermLocationContribute), resubmitLocation) // after an admin requested changes, back to pending
+		// Visitors can bookmark too, they have no other write access
+		user.GET("/bookmarks", middleware.Require(model.PermBookmarkManage), bookmarksProvider)
+		user.POST("/bookmarks/:id", middleware.Require(model.PermBookmarkManage), addBookmark)
+		user.DELETE("/bookmarks/:id", middleware.Require(model.PermBookmarkManage), removeBookmark)
 
-		// Next we will add user navigation, and location sharing feature
+		// Next we will add location sharing feature
 		// ...
 
-		// Admin-protected routes
+		// Admin-protected routes, each one gated by its own permission
 		admin := maps.Group("/")
+		admin.Use(middleware.UserAuthenticator)
 		// Static data on dashboard
-		admin.Use(middleware.UserAuthenticator, middleware.AdminAuthenticator)
-		admin.GET("/logs", systemLogsProvider)
-		admin.GET("/flag", flaggedReviewsProvider)
-		admin.GET("/newLocation", locationRequestProvider)
-		admin.GET("/indicators", indicatorProvider)
+		admin.GET("/logs", middleware.Require(model.PermLogsView), systemLogsProvider)          // ?user=&from=&to=&page=
+		admin.GET("/logs/auth", middleware.Require(model.PermLogsView), authEventsProvider)     // same filters, plus ?type=&outcome=
+		admin.GET("/flag", middleware.Require(model.PermReviewModerate), flaggedReviewsProvider)
+		admin.GET("/newLocation", middleware.Require(model.PermLocationApprove), locationRequestProvider)
+		admin.GET("/edits", middleware.Require(model.PermLocationApprove), locationEditsProvider) // ?page=, with the diff against the current location
+		admin.GET("/indicators", middleware.Require(model.PermLogsView), indicatorProvider)
 		// Actions
-		admin.POST("/flag/:id", flagAction)         // Allow action like allow or declined, in case of negative action add a mail request in the queue for the mail worker to send a mail of rejection to the user
-		admin.POST("/location/:id", locationAction) // Allow the action of user like allow or declined
-		admin.POST("/notice", addNotice)
+		admin.POST("/flag/:id", middleware.Require(model.PermReviewModerate), flagAction)           // Allow action like allow or declined, in case of negative action add a mail request in the queue for the mail worker to send a mail of rejection to the user
+		admin.POST("/location/:id", middleware.Require(model.PermLocationApprove), locationAction) // Allow the action of user like allow or declined
+		admin.POST("/location/:id/images", middleware.Require(model.PermLocationApprove), publishLocationImages) // retry, when the files could not be moved on the approval
+		admin.POST("/edits/:id", middleware.Require(model.PermLocationApprove), editAction)        // approve applies the edit and records the version
+		admin.POST("/notice", middleware.Require(model.PermNoticePublish), addNotice)                // coordinators can publish notices too
 		// TODO: add a env reload route for admin
 
 	}
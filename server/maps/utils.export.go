@@ -0,0 +1,201 @@
+package maps
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Writes one export file, begin and end wrap the locations
+type exportEncoder interface {
+	begin() error
+	location(l exportLocation) error
+	end() error
+}
+
+type exportFormat struct {
+	contentType string
+	extension   string
+	encoder     func(w io.Writer) exportEncoder
+}
+
+var exportFormats = map[string]exportFormat{
+	"geojson": {"application/geo+json", "geojson", func(w io.Writer) exportEncoder { return &geoJSONEncoder{w: w} }},
+	"kml":     {"application/vnd.google-earth.kml+xml", "kml", func(w io.Writer) exportEncoder { return &kmlEncoder{w: w} }},
+	"gpx":     {"application/gpx+xml", "gpx", func(w io.Writer) exportEncoder { return &gpxEncoder{w: w} }},
+}
+
+// Public URL of the cover image, served by the assets server
+func coverImageURL(l exportLocation) string {
+	if l.CoverImageId == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s/assets/%s.webp", strings.TrimSuffix(viper.GetString("asset_url"), "/"), l.CoverImageId)
+}
+
+// Properties shared by the formats, KML and GPX get them as extended data
+func exportProperties(l exportLocation) [][2]string {
+	return [][2]string{
+		{"locationId", l.LocationId.String()},
+		{"locationType", l.LocationType},
+		{"tag", l.Tag},
+		{"avgRating", fmt.Sprintf("%.1f", l.AverageRating)},
+		{"reviewCount", fmt.Sprint(l.ReviewCount)},
+		{"contact", l.Contact},
+		{"time", l.Time},
+		{"coverImage", coverImageURL(l)},
+		{"updatedAt", l.UpdatedAt.UTC().Format(time.RFC3339)},
+	}
+}
+
+type geoJSONEncoder struct {
+	w     io.Writer
+	count int
+}
+
+func (e *geoJSONEncoder) begin() error {
+	_, err := io.WriteString(e.w, `{"type":"FeatureCollection","features":[`)
+	return err
+}
+
+func (e *geoJSONEncoder) location(l exportLocation) error {
+	feature, err := json.Marshal(map[string]interface{}{
+		"type": "Feature",
+		"id":   l.LocationId,
+		"geometry": map[string]interface{}{
+			"type":        "Point",
+			"coordinates": [2]float32{l.Longitude, l.Latitude},
+		},
+		"properties": map[string]interface{}{
+			"name":         l.Name,
+			"description":  l.Description,
+			"locationType": l.LocationType,
+			"tag":          l.Tag,
+			"avgRating":    l.AverageRating,
+			"reviewCount":  l.ReviewCount,
+			"contact":      l.Contact,
+			"time":         l.Time,
+			"coverImage":   coverImageURL(l),
+			"updatedAt":    l.UpdatedAt.UTC().Format(time.RFC3339),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if e.count > 0 {
+		if _, err := io.WriteString(e.w, ","); err != nil {
+			return err
+		}
+	}
+	e.count++
+	_, err = e.w.Write(feature)
+	return err
+}
+
+func (e *geoJSONEncoder) end() error {
+	_, err := io.WriteString(e.w, "]}")
+	return err
+}
+
+type kmlData struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value"`
+}
+
+type kmlPlacemark struct {
+	XMLName     xml.Name  `xml:"Placemark"`
+	ID          string    `xml:"id,attr"`
+	Name        string    `xml:"name"`
+	Description string    `xml:"description,omitempty"`
+	Data        []kmlData `xml:"ExtendedData>Data"`
+	Coordinates string    `xml:"Point>coordinates"`
+}
+
+type kmlEncoder struct{ w io.Writer }
+
+func (e *kmlEncoder) begin() error {
+	_, err := io.WriteString(e.w, xml.Header+`<kml xmlns="http://www.opengis.net/kml/2.2"><Document><name>Campus Compass</name>`)
+	return err
+}
+
+func (e *kmlEncoder) location(l exportLocation) error {
+	placemark := kmlPlacemark{
+		ID:          l.LocationId.String(),
+		Name:        l.Name,
+		Description: l.Description,
+		Coordinates: fmt.Sprintf("%f,%f", l.Longitude, l.Latitude),
+	}
+	for _, p := range exportProperties(l) {
+		if p[1] != "" {
+			placemark.Data = append(placemark.Data, kmlData{p[0], p[1]})
+		}
+	}
+	return xml.NewEncoder(e.w).Encode(placemark)
+}
+
+func (e *kmlEncoder) end() error {
+	_, err := io.WriteString(e.w, "</Document></kml>")
+	return err
+}
+
+// Extension element, in our namespace as GPX requires
+type gpxProperty struct {
+	XMLName xml.Name `xml:"https://pclub.in/compass property"`
+	Name    string   `xml:"name,attr"`
+	Value   string   `xml:",chardata"`
+}
+
+type gpxLink struct {
+	Href string `xml:"href,attr"`
+	Text string `xml:"text,omitempty"`
+}
+
+type gpxWaypoint struct {
+	XMLName    xml.Name      `xml:"wpt"`
+	Lat        float32       `xml:"lat,attr"`
+	Lon        float32       `xml:"lon,attr"`
+	Time       string        `xml:"time"`
+	Name       string        `xml:"name"`
+	Desc       string        `xml:"desc,omitempty"`
+	Link       *gpxLink      `xml:"link,omitempty"`
+	Type       string        `xml:"type,omitempty"`
+	Extensions []gpxProperty `xml:"extensions>property,omitempty"`
+}
+
+type gpxEncoder struct{ w io.Writer }
+
+func (e *gpxEncoder) begin() error {
+	_, err := io.WriteString(e.w, xml.Header+`<gpx version="1.1" creator="Campus Compass" xmlns="http://www.topografix.com/GPX/1/1">`)
+	return err
+}
+
+func (e *gpxEncoder) location(l exportLocation) error {
+	waypoint := gpxWaypoint{
+		Lat:  l.Latitude,
+		Lon:  l.Longitude,
+		Time: l.UpdatedAt.UTC().Format(time.RFC3339),
+		Name: l.Name,
+		Desc: l.Description,
+		Type: l.LocationType,
+	}
+	if cover := coverImageURL(l); cover != "" {
+		waypoint.Link = &gpxLink{Href: cover, Text: "Cover image"}
+	}
+	// GPX has no field for these, they go in the extensions under our namespace
+	for _, p := range exportProperties(l) {
+		if p[1] != "" {
+			waypoint.Extensions = append(waypoint.Extensions, gpxProperty{Name: p[0], Value: p[1]})
+		}
+	}
+	return xml.NewEncoder(e.w).Encode(waypoint)
+}
+
+func (e *gpxEncoder) end() error {
+	_, err := io.WriteString(e.w, "</gpx>")
+	return err
+}
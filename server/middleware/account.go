@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"compass/connections"
+	"compass/model"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// A suspended, expired (visitors) or deleted account must be rejected even if it still holds a valid access token.
+// The state is cached for a short time, admin actions invalidate it right away.
+const accountCacheTTL = 30 * time.Second
+
+type cachedAccount struct {
+	active   bool
+	loadedAt time.Time
+}
+
+var (
+	accountCache   = map[uuid.UUID]cachedAccount{}
+	accountCacheMu sync.RWMutex
+)
+
+func accountActive(userID uuid.UUID) (bool, error) {
+	accountCacheMu.RLock()
+	entry, ok := accountCache[userID]
+	accountCacheMu.RUnlock()
+	if ok && time.Since(entry.loadedAt) < accountCacheTTL {
+		return entry.active, nil
+	}
+
+	var count int64
+	if err := connections.DB.
+		Model(&model.User{}).
+		Where("user_id = ? AND suspended_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", userID, time.Now()).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	active := count == 1
+
+	accountCacheMu.Lock()
+	accountCache[userID] = cachedAccount{active: active, loadedAt: time.Now()}
+	accountCacheMu.Unlock()
+	return active, nil
+}
+
+// InvalidateAccount drops the cached state, call it after suspending or deleting a user
+func InvalidateAccount(userID uuid.UUID) {
+	accountCacheMu.Lock()
+	delete(accountCache, userID)
+	accountCacheMu.Unlock()
+	InvalidatePermissions(userID)
+}
+
+// rejects the request if the account is suspended or deleted, returns false in that case
+func checkAccountActive(c *gin.Context, userID uuid.UUID) bool {
+	active, err := accountActive(userID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return false
+	}
+	if !active {
+		ClearAuthCookie(c)
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Your account is suspended, expired or no longer exists"})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"compass/connections"
+	"compass/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// Long user agents are cut, they are only for telling the devices apart
+const maxUserAgentLength = 255
+
+// RecordAuthEvent writes the event with the ip and user agent of the request.
+// The actor is the logged in user, else the target. A failed write is only logged, it never fails the request.
+func RecordAuthEvent(c *gin.Context, event model.AuthEvent) {
+	if event.ActorID == nil {
+		if userID, ok := c.Get("userID"); ok {
+			actor := userID.(uuid.UUID)
+			event.ActorID = &actor
+		} else {
+			event.ActorID = event.TargetID
+		}
+	}
+	event.IP = c.ClientIP()
+	event.UserAgent = c.Request.UserAgent()
+	if len(event.UserAgent) > maxUserAgentLength {
+		event.UserAgent = event.UserAgent[:maxUserAgentLength]
+	}
+	if err := connections.DB.Create(&event).Error; err != nil {
+		logrus.Errorf("Failed to record auth event %s: %v", event.Type, err)
+	}
+}
@@ -3,20 +3,21 @@ package middleware
 import (
 	"compass/connections"
 	"compass/model"
+	"errors"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/spf13/viper"
 	"gorm.io/gorm"
 )
 
 var authConfig = AuthConfig{
-	JWTSecretKey:       viper.GetString("jwt.secret"),
 	TokenExpiration:    5 * time.Minute,
 	RefreshTokenExpiry: 24 * 7 * time.Hour, // 7 days
+	MFATokenExpiry:     5 * time.Minute,    // time to enter the code after the password
 	CookieDomain:       viper.GetString("domain"),
 	// FIXME(prod): Set this value to true in prod
 	CookieSecure:       true, // Set to false in development
@@ -27,6 +28,11 @@ var authConfig = AuthConfig{
 
 // TODO: Extract the basic token extraction and verification out and keep just the user part
 func UserAuthenticator(c *gin.Context) {
+	// Scripts use personal access tokens instead of the cookies
+	if tokenString, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); found {
+		personalTokenAuthenticator(c, tokenString)
+		return
+	}
 	// Check for cookie
 	tokenString, err := c.Cookie("auth_token")
 	if err != nil {
@@ -35,31 +41,39 @@ func UserAuthenticator(c *gin.Context) {
 		return
 	}
 	// extract token
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(authConfig.JWTSecretKey), nil
-	})
+	token, err := ParseToken(tokenString, &JWTClaims{})
 	if err != nil || !token.Valid {
 		tryRefresh(c)
 		return
 	}
 	// Type conversion to *JWTClaims
 	claims, ok := token.Claims.(*JWTClaims)
-	if !ok {
+	// Session tokens carry no audience, the mfa and oidc tokens are signed by the same keys
+	if !ok || len(claims.Audience) > 0 {
 		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
 		return
 	}
+	if claims.Impersonator != nil && !checkImpersonation(c, claims) {
+		return
+	}
 	// Set the role here
 	// TODO: Find better way, here whenever i extract i need to do a check if that thing exist or not
 	c.Set("userID", claims.UserID)
 	c.Set("userRole", claims.Role)
 	c.Set("verified", claims.Verified)
 	c.Set("visibility", claims.Visibility)
+	c.Set("sessionID", claims.SessionID)
+	c.Set("mfa", claims.MFA)
 
 	// Verify the user power
-	if role := c.GetInt("userRole"); role < int(model.UserRole) {
+	if role := c.GetInt("userRole"); role < int(model.VisitorRole) {
 		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
 		return
 	}
+	// The token may outlive a suspension
+	if !checkAccountActive(c, claims.UserID) {
+		return
+	}
 	c.Next()
 }
 func tryRefresh(c *gin.Context) {
@@ -68,13 +82,7 @@ func tryRefresh(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
-	token, err := jwt.ParseWithClaims(
-		refreshToken,
-		&JWTClaimsRefresh{},
-		func(token *jwt.Token) (interface{}, error) {
-			return []byte(authConfig.JWTSecretKey), nil
-		},
-	)
+	token, err := ParseToken(refreshToken, &JWTClaimsRefresh{})
 
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
@@ -93,14 +101,35 @@ func tryRefresh(c *gin.Context) {
 		return
 	}
 
+	// Rotate the refresh token, every refresh token can be used only once
+	session, newRefreshToken, err := rotateSession(c, claims)
+	if err != nil {
+		ClearAuthCookie(c)
+		if errors.Is(err, errSessionNotFound) || errors.Is(err, errRefreshTokenReused) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Session expired, please login again"})
+		} else {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh session"})
+		}
+		return
+	}
+	if session.UserID != userID {
+		ClearAuthCookie(c)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+		return
+	}
+
+	if !checkAccountActive(c, userID) {
+		return
+	}
+
 	// Fetch user details from db
 
 	var modelUser model.User
 	result := connections.DB.
 		Model(&model.User{}).
-		Select("role", "is_verified").
+		Select("user_id", "role", "is_verified").
 		Preload("Profile", func(db *gorm.DB) *gorm.DB {
-			return db.Select("visibility")
+			return db.Select("user_id", "visibility")
 		}).
 		Where("user_id = ?", userID).
 		First(&modelUser)
@@ -114,31 +143,28 @@ func tryRefresh(c *gin.Context) {
 	visibility := modelUser.Profile.Visibility
 
 	//geneate new access token
-	newAccessToken, err := GenerateAccessToken(userID)
+	newAccessToken, err := GenerateAccessToken(userID, session.SessionID)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate access token"})
 		return
 	}
 	SetAuthCookie(c, newAccessToken)
+	// Empty when a parallel request already rotated it, that response carries the new cookie
+	if newRefreshToken != "" {
+		SetRefreshCookie(c, newRefreshToken)
+	}
 	// Set context values
 
 	c.Set("userID", userID)
 	c.Set("userRole", role)
 	c.Set("verified", verified)
 	c.Set("visibility", visibility)
+	c.Set("sessionID", session.SessionID)
+	c.Set("mfa", session.MFAVerified)
 
 	c.Next()
 }
 
-func AdminAuthenticator(c *gin.Context) {
-	// verify the role
-	if role := c.GetInt("userRole"); role < int(model.AdminRole) {
-		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
-		return
-	}
-	c.Next()
-}
-
 // But once the user verifies the email, the cookie will remain same hence will need to login again
 // TODO: I can fetch db and check if it is false and update it
 func EmailVerified(c *gin.Context) {
@@ -183,5 +209,3 @@ func CheckVisibility(c *gin.Context) {
 
 	c.Next()
 }
-
-// TODO: Visitors Auth System, Need to define exact permission
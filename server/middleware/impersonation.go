@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"compass/connections"
+	"compass/model"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// "View as user" for the admins. The impersonation token replaces only the auth cookie,
+// the refresh cookie of the admin is kept, so once it expires (or is dropped by
+// EndImpersonation) the next request refreshes back into the admin's own session.
+const impersonationExpiry = 15 * time.Minute
+
+// ImpersonationToken is an access token of the target user carrying the admin as the impersonator.
+// The token never passes the second factor and is read only, see UserAuthenticator.
+func ImpersonationToken(targetID uuid.UUID, adminID uuid.UUID) (string, time.Time, error) {
+	claims, err := userClaims(targetID)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expiresAt := time.Now().Add(impersonationExpiry)
+	claims.Impersonator = &adminID
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		Subject:   targetID.String(),
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		Issuer:    "pclub",
+	}
+	token, err := SignToken(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// StartImpersonation sets the impersonation token, only once the start is in the logs
+func StartImpersonation(c *gin.Context, token string) {
+	c.SetSameSite(authConfig.SameSiteMode)
+	c.SetCookie(
+		"auth_token",
+		token,
+		int(impersonationExpiry.Seconds()),
+		"/",
+		authConfig.CookieDomain,
+		authConfig.CookieSecure,
+		authConfig.CookieHTTPOnly,
+	)
+}
+
+// Only looking around, nothing can be changed on behalf of the user.
+// Returns false (and aborts) for the requests which are not allowed.
+func checkImpersonation(c *gin.Context, claims *JWTClaims) bool {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+	default:
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Not allowed while viewing as another user", "impersonating": true})
+		return false
+	}
+	// The admin may have lost the access meanwhile
+	if !checkAccountActive(c, *claims.Impersonator) {
+		return false
+	}
+	c.Set("impersonator", *claims.Impersonator)
+	return true
+}
+
+// NotImpersonating blocks the read requests which still act for the user (e.g. login into other apps).
+// Must be used after UserAuthenticator.
+func NotImpersonating(c *gin.Context) {
+	if _, impersonating := c.Get("impersonator"); impersonating {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Not allowed while viewing as another user", "impersonating": true})
+		return
+	}
+	c.Next()
+}
+
+// EndImpersonation drops the impersonation token and writes the end into the logs,
+// the admin is back on the next request through the refresh cookie
+func EndImpersonation(c *gin.Context) {
+	tokenString, err := c.Cookie("auth_token")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Not viewing as another user"})
+		return
+	}
+	claims := &JWTClaims{}
+	if _, err := ParseToken(tokenString, claims); err != nil || claims.Impersonator == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Not viewing as another user"})
+		return
+	}
+
+	var adminRole model.Role
+	if err := connections.DB.Model(&model.User{}).Select("role").Where("user_id = ?", *claims.Impersonator).Scan(&adminRole).Error; err != nil {
+		logrus.Errorf("Failed to load the role of %s: %v", *claims.Impersonator, err)
+	}
+	if err := connections.AddLog(connections.DB, model.Logs{
+		Title:       "Impersonation ended",
+		Description: "Stopped viewing as the user",
+		ActionTaker: adminRole.BaseRoleName(),
+		ActorID:     claims.Impersonator,
+		TargetID:    &claims.UserID,
+	}); err != nil {
+		logrus.Errorf("Failed to log the end of impersonation by %s: %v", *claims.Impersonator, err)
+	}
+
+	c.SetSameSite(authConfig.SameSiteMode)
+	c.SetCookie("auth_token", "", -1, "/", authConfig.CookieDomain, authConfig.CookieSecure, authConfig.CookieHTTPOnly)
+	c.JSON(http.StatusOK, gin.H{"message": "Stopped viewing as the user"})
+}
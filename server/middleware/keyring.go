@@ -0,0 +1,255 @@
+package middleware
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Tokens are signed with an asymmetric key (ES256 or EdDSA, decided by the key type), the kid header
+// tells which key. Every known key stays valid for verification and is published at /.well-known/jwks.json,
+// so other campus services can verify the tokens without any secret.
+//
+// Keys come from jwt.keys in secret.yml ({kid, pem}) and from jwt.keys_dir: <kid>.pem holds a private key,
+// <kid>.pub.pem only a public key (retired key, verification only).
+//
+// Rotation, without logging anyone out:
+//  1. add the new private key, restart. It is published but not used yet, so others can fetch it.
+//  2. point jwt.signing_kid to it, restart. New tokens use it, old ones still verify with the old key.
+//  3. after the refresh token expiry (7 days) remove the old key.
+//
+//	openssl genpkey -algorithm ed25519 -out keys/<kid>.pem
+//	openssl genpkey -algorithm EC -pkeyopt ec_paramgen_curve:P-256 -out keys/<kid>.pem
+
+type jwtKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private crypto.Signer // nil for retired keys
+	public  crypto.PublicKey
+}
+
+type keyring struct {
+	signing *jwtKey
+	keys    map[string]*jwtKey
+	// tokens from before the key pairs, accepted only while jwt.legacy_hs256 is on
+	legacySecret []byte
+}
+
+var (
+	ring     *keyring
+	ringOnce sync.Once
+)
+
+func getKeyring() *keyring {
+	ringOnce.Do(func() {
+		var err error
+		ring, err = loadKeyring()
+		if err != nil {
+			logrus.Fatalf("Failed to load the jwt keys: %v", err)
+		}
+	})
+	return ring
+}
+
+func loadKeyring() (*keyring, error) {
+	r := &keyring{keys: map[string]*jwtKey{}}
+
+	var configured []struct {
+		Kid string `mapstructure:"kid"`
+		PEM string `mapstructure:"pem"`
+	}
+	if err := viper.UnmarshalKey("jwt.keys", &configured); err != nil {
+		return nil, err
+	}
+	for _, entry := range configured {
+		if err := r.add(entry.Kid, []byte(entry.PEM)); err != nil {
+			return nil, err
+		}
+	}
+
+	if dir := viper.GetString("jwt.keys_dir"); dir != "" {
+		files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			kid := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(file), ".pem"), ".pub")
+			if err := r.add(kid, data); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if viper.GetBool("jwt.legacy_hs256") && viper.GetString("jwt.secret") != "" {
+		r.legacySecret = []byte(viper.GetString("jwt.secret"))
+	}
+
+	signingKid := viper.GetString("jwt.signing_kid")
+	if signingKid == "" && len(r.keys) == 0 {
+		// Dev setup, every restart logs everyone out
+		logrus.Warn("No jwt keys configured, using a temporary key")
+		private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		r.signing = &jwtKey{kid: "temporary", method: jwt.SigningMethodES256, private: private, public: &private.PublicKey}
+		r.keys[r.signing.kid] = r.signing
+		return r, nil
+	}
+	key, ok := r.keys[signingKid]
+	if !ok || key.private == nil {
+		return nil, fmt.Errorf("jwt.signing_kid %q is not a loaded private key", signingKid)
+	}
+	r.signing = key
+	return r, nil
+}
+
+func (r *keyring) add(kid string, data []byte) error {
+	if kid == "" {
+		return errors.New("jwt key without kid")
+	}
+	if _, exist := r.keys[kid]; exist {
+		return fmt.Errorf("duplicate jwt kid %q", kid)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return fmt.Errorf("jwt key %q is not PEM encoded", kid)
+	}
+
+	key := &jwtKey{kid: kid}
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("jwt key %q: %w", kid, err)
+		}
+		signer, ok := parsed.(crypto.Signer)
+		if !ok {
+			return fmt.Errorf("jwt key %q: unsupported key type", kid)
+		}
+		key.private = signer
+		key.public = signer.Public()
+	case "EC PRIVATE KEY":
+		parsed, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("jwt key %q: %w", kid, err)
+		}
+		key.private = parsed
+		key.public = &parsed.PublicKey
+	case "PUBLIC KEY":
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("jwt key %q: %w", kid, err)
+		}
+		key.public = parsed
+	default:
+		return fmt.Errorf("jwt key %q: unsupported PEM block %s", kid, block.Type)
+	}
+
+	switch public := key.public.(type) {
+	case *ecdsa.PublicKey:
+		if public.Curve != elliptic.P256() {
+			return fmt.Errorf("jwt key %q: only P-256 is supported for ES256", kid)
+		}
+		key.method = jwt.SigningMethodES256
+	case ed25519.PublicKey:
+		key.method = jwt.SigningMethodEdDSA
+	default:
+		return fmt.Errorf("jwt key %q: only ECDSA P-256 and Ed25519 keys are supported", kid)
+	}
+	r.keys[kid] = key
+	return nil
+}
+
+// SignToken signs the claims with the current signing key
+func SignToken(claims jwt.Claims) (string, error) {
+	key := getKeyring().signing
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.kid
+	return token.SignedString(key.private)
+}
+
+// ParseToken verifies the signature with the key named by the kid header, plus the usual claim checks
+func ParseToken(tokenString string, claims jwt.Claims, options ...jwt.ParserOption) (*jwt.Token, error) {
+	r := getKeyring()
+	methods := []string{jwt.SigningMethodES256.Alg(), jwt.SigningMethodEdDSA.Alg()}
+	if r.legacySecret != nil {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	options = append(options, jwt.WithValidMethods(methods))
+	return jwt.ParseWithClaims(tokenString, claims, r.keyFunc, options...)
+}
+
+func (r *keyring) keyFunc(token *jwt.Token) (interface{}, error) {
+	if token.Method == jwt.SigningMethodHS256 {
+		return r.legacySecret, nil
+	}
+	kid, _ := token.Header["kid"].(string)
+	key, ok := r.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+	// The alg header must match the key, never trust it on its own
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("alg %s does not match the key %q", token.Method.Alg(), kid)
+	}
+	return key.public, nil
+}
+
+// JWKSHandler publishes the public keys, in the JSON Web Key Set format (RFC 7517)
+func JWKSHandler(c *gin.Context) {
+	r := getKeyring()
+	kids := make([]string, 0, len(r.keys))
+	for kid := range r.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	keys := make([]gin.H, 0, len(kids))
+	for _, kid := range kids {
+		key := r.keys[kid]
+		jwk := gin.H{"kid": kid, "use": "sig", "alg": key.method.Alg()}
+		switch public := key.public.(type) {
+		case *ecdsa.PublicKey:
+			point, err := public.ECDH()
+			if err != nil {
+				continue
+			}
+			// uncompressed point: 0x04 | x | y
+			raw := point.Bytes()
+			jwk["kty"] = "EC"
+			jwk["crv"] = "P-256"
+			jwk["x"] = base64.RawURLEncoding.EncodeToString(raw[1:33])
+			jwk["y"] = base64.RawURLEncoding.EncodeToString(raw[33:])
+		case ed25519.PublicKey:
+			jwk["kty"] = "OKP"
+			jwk["crv"] = "Ed25519"
+			jwk["x"] = base64.RawURLEncoding.EncodeToString(public)
+		}
+		keys = append(keys, jwk)
+	}
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{"keys": keys})
+}
@@ -14,20 +14,31 @@ type JWTClaims struct {
 	Role     int       `json:"role"`
 	Verified bool      `json:"verified"`
 	Visibility bool    `json:"visibility"`
+	SessionID uuid.UUID `json:"sid"`
+	MFA      bool      `json:"mfa"` // the session has passed the second factor
+	// Set when an admin is viewing as this user, the token is then read only
+	Impersonator *uuid.UUID `json:"impersonator,omitempty"`
 	jwt.RegisteredClaims
 }
 
 type JWTClaimsRefresh struct {
-	UserID string `json:"user_id"`
+	UserID    string `json:"user_id"`
+	SessionID string `json:"sid"` // the session (token family) this refresh token belongs to
 	jwt.RegisteredClaims
 }
 
+// Issued after the password check for accounts with two factor enabled,
+// it only allows the second step of the login
+type JWTClaimsMFA struct {
+	UserID string `json:"user_id"`
+	jwt.RegisteredClaims
+}
 
 // AuthConfig holds authentication configuration
 type AuthConfig struct {
-	JWTSecretKey    string
 	TokenExpiration time.Duration
 	RefreshTokenExpiry time.Duration
+	MFATokenExpiry  time.Duration
 	CookieDomain    string
 	CookieSecure    bool
 	CookieHTTPOnly  bool
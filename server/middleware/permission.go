@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"compass/connections"
+	"compass/model"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Permissions are resolved from the database and cached for a short time,
+// changes done through the roles api invalidate the entry right away.
+const permissionCacheTTL = time.Minute
+
+type cachedPermissions struct {
+	permissions map[string]bool
+	loadedAt    time.Time
+}
+
+var (
+	permissionCache   = map[uuid.UUID]cachedPermissions{}
+	permissionCacheMu sync.RWMutex
+)
+
+// UserPermissions returns the effective permissions of the user:
+// the ones of the base role (from the legacy integer role) plus the ones of every assigned role
+func UserPermissions(userID uuid.UUID, role model.Role) (map[string]bool, error) {
+	permissionCacheMu.RLock()
+	entry, ok := permissionCache[userID]
+	permissionCacheMu.RUnlock()
+	if ok && time.Since(entry.loadedAt) < permissionCacheTTL {
+		return entry.permissions, nil
+	}
+
+	var names []string
+	if err := connections.DB.
+		Table("role_permissions").
+		Distinct("permission_name").
+		Where("access_role_name = ? OR access_role_name IN (?)",
+			role.BaseRoleName(),
+			connections.DB.Model(&model.RoleAssignment{}).Select("role_name").Where("user_id = ?", userID),
+		).
+		Pluck("permission_name", &names).Error; err != nil {
+		return nil, err
+	}
+	permissions := make(map[string]bool, len(names))
+	for _, name := range names {
+		permissions[name] = true
+	}
+
+	permissionCacheMu.Lock()
+	permissionCache[userID] = cachedPermissions{permissions: permissions, loadedAt: time.Now()}
+	permissionCacheMu.Unlock()
+	return permissions, nil
+}
+
+// InvalidatePermissions drops the cached permissions, call it after changing the roles of a user
+func InvalidatePermissions(userID uuid.UUID) {
+	permissionCacheMu.Lock()
+	delete(permissionCache, userID)
+	permissionCacheMu.Unlock()
+}
+
+// Require lets the request through when the user holds any one of the given permissions
+// (and, for personal access tokens, the token lists it).
+// Must be used after UserAuthenticator.
+func Require(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exist := c.Get("userID")
+		if !exist {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		granted, err := UserPermissions(userID.(uuid.UUID), model.Role(c.GetInt("userRole")))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify permissions"})
+			return
+		}
+		// Personal access tokens are limited to the permissions listed on them
+		scoped, isToken := c.Get("tokenPermissions")
+		mfaMissing, scopeMissing := false, false
+		for _, permission := range permissions {
+			if !granted[permission] {
+				continue
+			}
+			if isToken && !slices.Contains(scoped.([]string), permission) {
+				scopeMissing = true
+				continue
+			}
+			// Privileged actions need a session which passed the second factor
+			if model.IsPrivileged(permission) && !c.GetBool("mfa") {
+				mfaMissing = true
+				continue
+			}
+			c.Next()
+			return
+		}
+		if scopeMissing {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "The token does not have the permission for this action"})
+			return
+		}
+		if mfaMissing {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Two factor authentication is required for this action", "mfaRequired": true})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+	}
+}
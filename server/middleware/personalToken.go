@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"compass/connections"
+	"compass/model"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Personal access tokens start with this, makes them easy to spot in leaked configs and logs
+const PersonalTokenPrefix = "cpat_"
+
+// last_used_at is written at most once per this interval, not on every request of a busy script
+const personalTokenTouchInterval = time.Minute
+
+// GeneratePersonalToken returns a new token and the hash to store
+func GeneratePersonalToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token := PersonalTokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return token, hashTokenID(token), nil
+}
+
+// RevokePersonalTokens revokes every token of the user, e.g. after a password reset
+func RevokePersonalTokens(userID uuid.UUID) error {
+	return connections.DB.
+		Model(&model.PersonalAccessToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// Called by UserAuthenticator when the request carries a bearer token instead of the cookies
+func personalTokenAuthenticator(c *gin.Context, tokenString string) {
+	var token model.PersonalAccessToken
+	err := connections.DB.
+		Where("token_hash = ? AND revoked_at IS NULL", hashTokenID(tokenString)).
+		First(&token).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if time.Now().After(token.ExpiresAt) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token expired"})
+		return
+	}
+	if !checkAccountActive(c, token.UserID) {
+		return
+	}
+
+	var modelUser model.User
+	if err := connections.DB.
+		Model(&model.User{}).
+		Select("user_id", "role", "is_verified").
+		Preload("Profile", func(db *gorm.DB) *gorm.DB {
+			return db.Select("user_id", "visibility")
+		}).
+		Where("user_id = ?", token.UserID).
+		First(&modelUser).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if modelUser.Role < model.VisitorRole {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+
+	now := time.Now()
+	if err := connections.DB.
+		Model(&model.PersonalAccessToken{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", token.ID, now.Add(-personalTokenTouchInterval)).
+		Updates(map[string]interface{}{"last_used_at": now, "last_used_ip": c.ClientIP()}).Error; err != nil {
+		logrus.Errorf("Failed to update last use of token %s: %v", token.ID, err)
+	}
+
+	c.Set("userID", token.UserID)
+	c.Set("userRole", int(modelUser.Role))
+	c.Set("verified", modelUser.IsVerified)
+	c.Set("visibility", modelUser.Profile.Visibility)
+	// Privileged permissions can only be put on a token from a session which passed the second factor
+	c.Set("mfa", true)
+	c.Set("tokenID", token.ID)
+	c.Set("tokenPermissions", token.Permissions)
+	c.Next()
+}
+
+// SessionOnly keeps personal access tokens away from the account settings (sessions, two factor, tokens etc.).
+// Must be used after UserAuthenticator.
+func SessionOnly(c *gin.Context) {
+	if _, isToken := c.Get("tokenID"); isToken {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Not allowed with a personal access token"})
+		return
+	}
+	c.Next()
+}
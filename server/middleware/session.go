@@ -0,0 +1,181 @@
+package middleware
+
+import (
+	"compass/connections"
+	"compass/model"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// Browsers fire several requests together once the access token expires, all of them
+// carrying the same refresh cookie. Only the first one rotates, the others arriving within
+// this window are let through instead of being treated as a stolen token.
+const refreshGracePeriod = 30 * time.Second
+
+var (
+	errSessionNotFound    = errors.New("session not found or revoked")
+	errRefreshTokenReused = errors.New("refresh token reused")
+)
+
+func hashTokenID(tokenID string) string {
+	sum := sha256.Sum256([]byte(tokenID))
+	return hex.EncodeToString(sum[:])
+}
+
+// StartSession creates a new session for the device making the request and sets both cookies.
+// Used by every flow that logs a user in (login, email verification etc.),
+// mfaVerified tells whether the second factor was checked on the way.
+func StartSession(c *gin.Context, userID uuid.UUID, mfaVerified bool) error {
+	tokenID := uuid.NewString()
+	now := time.Now()
+	session := model.Session{
+		UserID:      userID,
+		TokenHash:   hashTokenID(tokenID),
+		RotatedAt:   now,
+		UserAgent:   c.Request.UserAgent(),
+		IP:          c.ClientIP(),
+		LastUsedAt:  now,
+		ExpiresAt:   now.Add(authConfig.RefreshTokenExpiry),
+		MFAVerified: mfaVerified,
+	}
+	if err := connections.DB.Create(&session).Error; err != nil {
+		return err
+	}
+
+	accessToken, err := GenerateAccessToken(userID, session.SessionID)
+	if err != nil {
+		return err
+	}
+	refreshToken, err := GenerateRefreshToken(userID, session.SessionID, tokenID)
+	if err != nil {
+		return err
+	}
+
+	// Clear the previous cookie
+	ClearAuthCookie(c)
+	SetAuthCookie(c, accessToken)
+	SetRefreshCookie(c, refreshToken)
+	return nil
+}
+
+// rotateSession swaps the refresh token of a session for a new one.
+// Returns an empty token (and no error) when the request lost a concurrent rotation,
+// in that case the cookie set by the winning request is kept.
+func rotateSession(c *gin.Context, claims *JWTClaimsRefresh) (model.Session, string, error) {
+	var session model.Session
+	sessionID, err := uuid.Parse(claims.SessionID)
+	if err != nil {
+		return session, "", errSessionNotFound
+	}
+	now := time.Now()
+	if err := connections.DB.
+		Where("session_id = ? AND revoked_at IS NULL AND expires_at > ?", sessionID, now).
+		First(&session).Error; err != nil {
+		return session, "", errSessionNotFound
+	}
+
+	presented := hashTokenID(claims.ID)
+	if presented != session.TokenHash {
+		if presented == session.PreviousTokenHash && now.Sub(session.RotatedAt) < refreshGracePeriod {
+			return session, "", nil
+		}
+		// An already rotated token came back, someone else holds a copy of it.
+		// Kill the whole family so neither copy can be used anymore.
+		logrus.Warnf("Refresh token reuse detected for session %s of user %s from %s", session.SessionID, session.UserID, c.ClientIP())
+		if err := RevokeSession(session.UserID, session.SessionID); err != nil {
+			logrus.Errorf("Failed to revoke session %s: %v", session.SessionID, err)
+		}
+		return session, "", errRefreshTokenReused
+	}
+
+	tokenID := uuid.NewString()
+	result := connections.DB.
+		Model(&model.Session{}).
+		// token_hash in the where clause makes the swap atomic between parallel requests
+		Where("session_id = ? AND token_hash = ?", session.SessionID, presented).
+		Updates(map[string]interface{}{
+			"token_hash":          hashTokenID(tokenID),
+			"previous_token_hash": presented,
+			"rotated_at":          now,
+			"last_used_at":        now,
+			"ip":                  c.ClientIP(),
+			"user_agent":          c.Request.UserAgent(),
+			"expires_at":          now.Add(authConfig.RefreshTokenExpiry),
+		})
+	if result.Error != nil {
+		return session, "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		return session, "", nil
+	}
+
+	refreshToken, err := GenerateRefreshToken(session.UserID, session.SessionID, tokenID)
+	if err != nil {
+		return session, "", err
+	}
+	return session, refreshToken, nil
+}
+
+// MarkSessionMFAVerified upgrades the current session once the user proves the second factor,
+// e.g. right after enabling two factor
+func MarkSessionMFAVerified(c *gin.Context, userID uuid.UUID, sessionID uuid.UUID) error {
+	if err := connections.DB.
+		Model(&model.Session{}).
+		Where("session_id = ? AND user_id = ?", sessionID, userID).
+		Update("mfa_verified", true).Error; err != nil {
+		return err
+	}
+	accessToken, err := GenerateAccessToken(userID, sessionID)
+	if err != nil {
+		return err
+	}
+	SetAuthCookie(c, accessToken)
+	return nil
+}
+
+// RevokeSession logs out a single device of the user
+func RevokeSession(userID uuid.UUID, sessionID uuid.UUID) error {
+	return connections.DB.
+		Model(&model.Session{}).
+		Where("session_id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeAllSessions logs the user out of every device.
+// Access tokens already issued stay valid till they expire (authConfig.TokenExpiration).
+func RevokeAllSessions(userID uuid.UUID) error {
+	return connections.DB.
+		Model(&model.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// EndSession revokes the session behind the refresh cookie (if any) and clears the cookies
+func EndSession(c *gin.Context) {
+	defer ClearAuthCookie(c)
+	refreshToken, err := c.Cookie("refresh_token")
+	if err != nil {
+		return
+	}
+	claims := &JWTClaimsRefresh{}
+	if _, err := ParseToken(refreshToken, claims); err != nil {
+		return
+	}
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return
+	}
+	sessionID, err := uuid.Parse(claims.SessionID)
+	if err != nil {
+		return
+	}
+	if err := RevokeSession(userID, sessionID); err != nil {
+		logrus.Errorf("Failed to revoke session %s on logout: %v", sessionID, err)
+	}
+}
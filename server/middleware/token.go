@@ -11,10 +11,14 @@ import (
 	"gorm.io/gorm"
 )
 
-func GenerateRefreshToken(userID uuid.UUID) (string, error) {
+// GenerateRefreshToken signs a refresh token for the session, tokenID is the jti
+// whose hash is kept on the session row to detect reuse of rotated tokens.
+func GenerateRefreshToken(userID uuid.UUID, sessionID uuid.UUID, tokenID string) (string, error) {
 	claims := JWTClaimsRefresh{
-		UserID: userID.String(),
+		UserID:    userID.String(),
+		SessionID: sessionID.String(),
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
 			Subject:   userID.String(),
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(authConfig.RefreshTokenExpiry)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -22,12 +26,38 @@ func GenerateRefreshToken(userID uuid.UUID) (string, error) {
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(authConfig.JWTSecretKey))
+	return SignToken(claims)
 }
 
-func GenerateAccessToken(userID uuid.UUID) (string, error) {
+func GenerateAccessToken(userID uuid.UUID, sessionID uuid.UUID) (string, error) {
+	claims, err := userClaims(userID)
+	if err != nil {
+		return "", err
+	}
 
+	// Whether this device passed the second factor
+	var mfa bool
+	if err := connections.DB.
+		Model(&model.Session{}).
+		Select("mfa_verified").
+		Where("session_id = ?", sessionID).
+		Scan(&mfa).Error; err != nil {
+		return "", err
+	}
+
+	claims.SessionID = sessionID
+	claims.MFA = mfa
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		Subject:   userID.String(),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(authConfig.TokenExpiration)),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		Issuer:    "pclub",
+	}
+	return SignToken(claims)
+}
+
+// userClaims loads the role, verification and visibility of the user for an access token
+func userClaims(userID uuid.UUID) (JWTClaims, error) {
 	var modelUser model.User
 	result := connections.DB.
 		Model(&model.User{}).
@@ -40,27 +70,69 @@ func GenerateAccessToken(userID uuid.UUID) (string, error) {
 		First(&modelUser)
 
 	if result.Error != nil {
-		return "", result.Error
+		return JWTClaims{}, result.Error
 	}
 
-	role := int(modelUser.Role)
-	verified := modelUser.IsVerified
-	visibility := modelUser.Profile.Visibility
-
-	claims := JWTClaims{
+	return JWTClaims{
 		UserID:     userID,
-		Role:       role,
-		Verified:   verified,
-		Visibility: visibility,
+		Role:       int(modelUser.Role),
+		Verified:   modelUser.IsVerified,
+		Visibility: modelUser.Profile.Visibility,
+	}, nil
+}
+
+// GenerateMFAToken issues the short lived token for the second step of the login
+func GenerateMFAToken(userID uuid.UUID) (string, error) {
+	claims := JWTClaimsMFA{
+		UserID: userID.String(),
 		RegisteredClaims: jwt.RegisteredClaims{
 			Subject:   userID.String(),
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(authConfig.TokenExpiration)),
+			Audience:  jwt.ClaimStrings{"mfa"},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(authConfig.MFATokenExpiry)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			Issuer:    "pclub",
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(authConfig.JWTSecretKey))
+	return SignToken(claims)
+}
+
+// ParseMFAToken returns the user who passed the password step, from the mfa cookie
+func ParseMFAToken(c *gin.Context) (uuid.UUID, error) {
+	tokenString, err := c.Cookie("mfa_token")
+	if err != nil {
+		return uuid.Nil, err
+	}
+	claims := &JWTClaimsMFA{}
+	if _, err := ParseToken(tokenString, claims, jwt.WithAudience("mfa")); err != nil {
+		return uuid.Nil, err
+	}
+	return uuid.Parse(claims.UserID)
+}
+
+func SetMFACookie(c *gin.Context, token string) {
+	c.SetSameSite(authConfig.SameSiteMode)
+	c.SetCookie(
+		"mfa_token",
+		token,
+		int(authConfig.MFATokenExpiry.Seconds()),
+		"/",
+		authConfig.CookieDomain,
+		authConfig.CookieSecure,
+		authConfig.CookieHTTPOnly,
+	)
+}
+
+func ClearMFACookie(c *gin.Context) {
+	c.SetSameSite(authConfig.SameSiteMode)
+	c.SetCookie(
+		"mfa_token",
+		"",
+		-1,
+		"/",
+		authConfig.CookieDomain,
+		authConfig.CookieSecure,
+		authConfig.CookieHTTPOnly,
+	)
 }
 
 func SetAuthCookie(c *gin.Context, token string) {
@@ -0,0 +1,25 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PersonalAccessToken lets scripts call the api as the user, through the Authorization: Bearer header.
+// Only the hash of the token is kept, the token itself is shown once when created.
+// It can only use the permissions listed on it (and only while the user still holds them).
+type PersonalAccessToken struct {
+	ID          uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"-"`
+	Name        string     `json:"name"`
+	Prefix      string     `json:"prefix"` // first characters of the token, to tell them apart
+	TokenHash   string     `gorm:"uniqueIndex;not null" json:"-"`
+	Permissions []string   `gorm:"serializer:json" json:"permissions"`
+	ExpiresAt   time.Time  `gorm:"index" json:"expiresAt"`
+	LastUsedAt  *time.Time `json:"lastUsedAt"`
+	LastUsedIP  string     `json:"lastUsedIp"`
+	RevokedAt   *time.Time `gorm:"index" json:"-"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	User        *User      `gorm:"foreignKey:UserID;references:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
@@ -1,11 +1,16 @@
 package model
 
-import "gorm.io/gorm"
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
 
 type Logs struct {
 	gorm.Model
-	LogId           string `gorm:"uniqueIndex" json:"log_id"`
-	Title           string `json:"title" binding:"required"`
-	Description     string `json:"description"`
-	ActionTaker Role   `gorm:"type:varchar(10);check:action_taker IN ('admin','bot','user')"`
+	LogId       string     `gorm:"uniqueIndex" json:"log_id"`
+	Title       string     `json:"title" binding:"required"`
+	Description string     `json:"description"`
+	ActionTaker string     `gorm:"type:varchar(10);check:action_taker IN ('admin','bot','user')" json:"action_taker"` // Role.BaseRoleName() of the actor
+	ActorID     *uuid.UUID `gorm:"type:uuid;index" json:"actor_id"`                                                   // who did it, nil for the system
+	TargetID    *uuid.UUID `gorm:"type:uuid;index" json:"target_id"`                                                  // the user acted upon, if any
 }
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Types of the auth events
+const (
+	EventLogin                = "login"
+	EventLoginSecondFactor    = "login_2fa"
+	EventMagicLinkRequest     = "magic_link_request"
+	EventMagicLogin           = "magic_login"
+	EventEmailVerify          = "email_verify"
+	EventPasswordResetRequest = "password_reset_request"
+	EventPasswordReset        = "password_reset"
+	EventVisibilityChange     = "visibility_change"
+	EventProfileDelete        = "profile_delete"
+)
+
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// AuthEvent is a security relevant action on an account, written by middleware.RecordAuthEvent.
+// The admin actions are in the system logs (Logs) instead.
+type AuthEvent struct {
+	ID        uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	CreatedAt time.Time  `gorm:"index" json:"createdAt"`
+	Type      string     `gorm:"type:varchar(40);not null;index" json:"type"`
+	Outcome   string     `gorm:"type:varchar(10);not null;check:outcome IN ('success','failure')" json:"outcome"`
+	Reason    string     `json:"reason,omitempty"`                // why it failed, or any detail
+	ActorID   *uuid.UUID `gorm:"type:uuid;index" json:"actorId"`  // who did it, nil when not known (login with an unknown email)
+	TargetID  *uuid.UUID `gorm:"type:uuid;index" json:"targetId"` // the account acted upon
+	Email     string     `gorm:"index" json:"email,omitempty"`    // as typed, for the attempts on unknown accounts
+	IP        string     `gorm:"type:varchar(45)" json:"ip"`
+	UserAgent string     `json:"userAgent"`
+}
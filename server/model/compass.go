@@ -14,6 +14,8 @@ const (
 	Approved      Status = "approved"
 	Rejected      Status = "rejected"      // if rejected by admin finally
 	RejectedByBot Status = "rejectedByBot" // if rejected by bot
+	// Sent back to the contributor by the admin, the note says what to fix
+	ChangesRequested Status = "changesRequested"
 )
 
 type Location struct {
@@ -23,12 +25,13 @@ type Location struct {
 	LocationId    uuid.UUID      `json:"locationId" gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
 	Name          string         `json:"name" binding:"required"`
 	Description   string         `json:"description"`
-	Latitude      float32        `json:"latitude" binding:"required"`
-	Longitude     float32        `json:"longitude" binding:"required"`
+	Latitude      float32        `json:"latitude" binding:"required" gorm:"index:idx_location_lat_lng"` // bounding box of the nearby search
+	Longitude     float32        `json:"longitude" binding:"required" gorm:"index:idx_location_lat_lng"`
 	LocationType  string         `json:"locationType"`
-	Status        Status         `json:"status" gorm:"type:varchar(20);check:status IN ('pending','approved','rejected')"`          // once the location is approved by the admin it will be publicly available
-	ContributedBy uuid.UUID      `json:"contributedBy"`                                                                             // This is the foreign key
-	User          *User          `gorm:"foreignKey:ContributedBy;references:UserID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"` // many location to single user binding
+	Status        Status         `json:"status" gorm:"type:varchar(20);check:status IN ('pending','approved','rejected','changesRequested')"` // once the location is approved by the admin it will be publicly available
+	ReviewNote    string         `json:"reviewNote"`                                                                                          // message of the admin on rejection or on requesting changes
+	ContributedBy uuid.UUID      `json:"contributedBy"`                                                                                       // This is the foreign key
+	User          *User          `gorm:"foreignKey:ContributedBy;references:UserID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`           // many location to single user binding
 	AverageRating float32        `json:"avgRating"`
 	ReviewCount   int64          `json:"reviewCount"`
 	Tag           string         `json:"tag"`
@@ -69,3 +72,12 @@ type Review struct {
 	User          *User          `gorm:"foreignKey:ContributedBy;references:UserID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
 	Images        []Image        `gorm:"polymorphic:ParentAsset;" json:"images"` // base name, parentAsset, it will attach the ID itself
 }
+
+// Bookmark is a location saved by a user (or a visitor) for later
+type Bookmark struct {
+	UserID     uuid.UUID `gorm:"type:uuid;primaryKey" json:"-"`
+	LocationId uuid.UUID `gorm:"type:uuid;primaryKey" json:"locationId"`
+	CreatedAt  time.Time `json:"createdAt"`
+	User       *User     `gorm:"foreignKey:UserID;references:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Location   *Location `gorm:"foreignKey:LocationId;references:LocationId;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"location,omitempty"`
+}
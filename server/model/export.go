@@ -0,0 +1,28 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Status of the personal data exports
+const (
+	ExportPending = "pending"
+	ExportReady   = "ready"
+	ExportFailed  = "failed"
+)
+
+// DataExport is a zip of everything Compass holds about the user, built by the export worker.
+// The download link is mailed once ready, only the hash of its token is kept.
+type DataExport struct {
+	ID          uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"-"`
+	Status      string     `gorm:"type:varchar(20);not null;check:status IN ('pending','ready','failed')" json:"status"`
+	TokenHash   string     `json:"-"`
+	Size        int64      `json:"size"`                   // bytes of the zip
+	ExpiresAt   *time.Time `gorm:"index" json:"expiresAt"` // of the download link, the file is removed after this
+	CompletedAt *time.Time `json:"completedAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	User        *User      `gorm:"foreignKey:UserID;references:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
@@ -0,0 +1,192 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LocationChanges are the edited fields of a location, nil ones are left as they are
+type LocationChanges struct {
+	Name         *string  `json:"name,omitempty" binding:"omitempty,min=1,max=100"`
+	Description  *string  `json:"description,omitempty" binding:"omitempty,max=250"`
+	Latitude     *float32 `json:"latitude,omitempty" binding:"omitempty,min=-90,max=90"`
+	Longitude    *float32 `json:"longitude,omitempty" binding:"omitempty,min=-180,max=180"`
+	LocationType *string  `json:"locationType,omitempty"`
+	Tag          *string  `json:"tag,omitempty"`
+	Contact      *string  `json:"contact,omitempty"`
+	Time         *string  `json:"time,omitempty"`
+}
+
+// FieldDiff is one changed field, for the review of an edit
+type FieldDiff struct {
+	Field    string      `json:"field"`
+	Current  interface{} `json:"current"`
+	Proposed interface{} `json:"proposed"`
+}
+
+// field binds a change to its column and to the value on the location
+type locationField struct {
+	name     string // json name
+	column   string
+	proposed interface{} // nil when unchanged
+	current  interface{}
+}
+
+func (ch *LocationChanges) fields(l Location) []locationField {
+	deref := func(s *string) interface{} {
+		if s == nil {
+			return nil
+		}
+		return *s
+	}
+	derefFloat := func(f *float32) interface{} {
+		if f == nil {
+			return nil
+		}
+		return *f
+	}
+	return []locationField{
+		{"name", "name", deref(ch.Name), l.Name},
+		{"description", "description", deref(ch.Description), l.Description},
+		{"latitude", "latitude", derefFloat(ch.Latitude), l.Latitude},
+		{"longitude", "longitude", derefFloat(ch.Longitude), l.Longitude},
+		{"locationType", "location_type", deref(ch.LocationType), l.LocationType},
+		{"tag", "tag", deref(ch.Tag), l.Tag},
+		{"contact", "contact", deref(ch.Contact), l.Contact},
+		{"time", "time", deref(ch.Time), l.Time},
+	}
+}
+
+// Normalize trims the text fields, a field may be changed but never blanked
+func (ch *LocationChanges) Normalize() error {
+	for _, f := range []struct {
+		name  string
+		value *string
+	}{
+		{"name", ch.Name}, {"description", ch.Description}, {"locationType", ch.LocationType},
+		{"tag", ch.Tag}, {"contact", ch.Contact}, {"time", ch.Time},
+	} {
+		if f.value == nil {
+			continue
+		}
+		if *f.value = strings.TrimSpace(*f.value); *f.value == "" {
+			return fmt.Errorf("%s must not be empty", f.name)
+		}
+	}
+	return nil
+}
+
+// Changed keeps only the fields differing from the location, the others must not be written back
+func (ch *LocationChanges) Changed(l Location) LocationChanges {
+	var changed LocationChanges
+	for _, d := range ch.Diff(l) {
+		switch d.Field {
+		case "name":
+			changed.Name = ch.Name
+		case "description":
+			changed.Description = ch.Description
+		case "latitude":
+			changed.Latitude = ch.Latitude
+		case "longitude":
+			changed.Longitude = ch.Longitude
+		case "locationType":
+			changed.LocationType = ch.LocationType
+		case "tag":
+			changed.Tag = ch.Tag
+		case "contact":
+			changed.Contact = ch.Contact
+		case "time":
+			changed.Time = ch.Time
+		}
+	}
+	return changed
+}
+
+// Columns to update on the location
+func (ch *LocationChanges) Columns() map[string]interface{} {
+	columns := map[string]interface{}{}
+	if ch == nil {
+		return columns
+	}
+	for _, f := range ch.fields(Location{}) {
+		if f.proposed != nil {
+			columns[f.column] = f.proposed
+		}
+	}
+	return columns
+}
+
+// Diff against the location, fields already holding the proposed value are left out
+func (ch *LocationChanges) Diff(l Location) []FieldDiff {
+	var diff []FieldDiff
+	for _, f := range ch.fields(l) {
+		if f.proposed != nil && f.proposed != f.current {
+			diff = append(diff, FieldDiff{f.name, f.current, f.proposed})
+		}
+	}
+	return diff
+}
+
+// Previous values on the location of the changed fields, kept in the version history
+func (ch *LocationChanges) Previous(l Location) LocationChanges {
+	var previous LocationChanges
+	if ch.Name != nil {
+		previous.Name = &l.Name
+	}
+	if ch.Description != nil {
+		previous.Description = &l.Description
+	}
+	if ch.Latitude != nil {
+		previous.Latitude = &l.Latitude
+	}
+	if ch.Longitude != nil {
+		previous.Longitude = &l.Longitude
+	}
+	if ch.LocationType != nil {
+		previous.LocationType = &l.LocationType
+	}
+	if ch.Tag != nil {
+		previous.Tag = &l.Tag
+	}
+	if ch.Contact != nil {
+		previous.Contact = &l.Contact
+	}
+	if ch.Time != nil {
+		previous.Time = &l.Time
+	}
+	return previous
+}
+
+// LocationEdit is a change proposed by a user to an approved location, applied once an admin accepts it
+type LocationEdit struct {
+	ID            uuid.UUID       `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	LocationId    uuid.UUID       `gorm:"type:uuid;not null;index" json:"locationId"`
+	ContributedBy uuid.UUID       `gorm:"type:uuid;not null;index" json:"contributedBy"`
+	Changes       LocationChanges `gorm:"serializer:json" json:"changes"` // only the changed fields
+	Note          string          `json:"note"`                           // why, from the contributor
+	Status        Status          `gorm:"type:varchar(20);not null;index;check:status IN ('pending','approved','rejected')" json:"status"`
+	ReviewedBy    *uuid.UUID      `gorm:"type:uuid" json:"reviewedBy"`
+	ReviewNote    string          `json:"reviewNote"`
+	ReviewedAt    *time.Time      `json:"reviewedAt"`
+	CreatedAt     time.Time       `json:"createdAt"`
+	UpdatedAt     time.Time       `json:"-"`
+	Location      *Location       `gorm:"foreignKey:LocationId;references:LocationId;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"location,omitempty"`
+	User          *User           `gorm:"foreignKey:ContributedBy;references:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+// LocationVersion is an entry of the history of a location, written when an edit is applied
+type LocationVersion struct {
+	ID         uuid.UUID       `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	LocationId uuid.UUID       `gorm:"type:uuid;not null;uniqueIndex:idx_location_version" json:"locationId"`
+	Version    int             `gorm:"not null;uniqueIndex:idx_location_version" json:"version"` // 1 for the first edit
+	EditID     *uuid.UUID      `gorm:"type:uuid" json:"editId"`
+	EditedBy   uuid.UUID       `gorm:"type:uuid;index" json:"editedBy"` // the contributor of the edit
+	ApprovedBy uuid.UUID       `gorm:"type:uuid" json:"approvedBy"`
+	Before     LocationChanges `gorm:"serializer:json" json:"before"`
+	After      LocationChanges `gorm:"serializer:json" json:"after"`
+	CreatedAt  time.Time       `json:"createdAt"`
+	Location   *Location       `gorm:"foreignKey:LocationId;references:LocationId;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
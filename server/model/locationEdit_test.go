@@ -0,0 +1,53 @@
+package model
+
+import (
+	"testing"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+func TestLocationChangesNormalize(t *testing.T) {
+	changes := LocationChanges{Name: ptr("  Hall 1 "), Time: ptr("\t9-5\n")}
+	if err := changes.Normalize(); err != nil {
+		t.Fatal(err)
+	}
+	if *changes.Name != "Hall 1" || *changes.Time != "9-5" {
+		t.Fatalf("Normalize = %q, %q, want trimmed values", *changes.Name, *changes.Time)
+	}
+
+	for _, blank := range []LocationChanges{
+		{Name: ptr("")},
+		{Name: ptr("   ")},
+		{Description: ptr(" \t")},
+		{LocationType: ptr("")},
+		{Tag: ptr(" ")},
+		{Contact: ptr("")},
+		{Time: ptr("\n")},
+	} {
+		if err := blank.Normalize(); err == nil {
+			t.Errorf("Normalize(%+v) = nil, want an error for the blank field", blank)
+		}
+	}
+}
+
+func TestLocationChangesChanged(t *testing.T) {
+	location := Location{Name: "Hall 1", Time: "9-5", Latitude: 26.5}
+	proposal := LocationChanges{Name: ptr("Hall One"), Time: ptr("9-5"), Latitude: ptr(float32(26.5))}
+
+	changed := proposal.Changed(location)
+	if changed.Name == nil || *changed.Name != "Hall One" {
+		t.Fatalf("Changed lost the name: %+v", changed)
+	}
+	if changed.Time != nil || changed.Latitude != nil {
+		t.Fatalf("Changed kept the unchanged fields: %+v", changed)
+	}
+	if columns := changed.Columns(); len(columns) != 1 || columns["name"] != "Hall One" {
+		t.Fatalf("Columns = %v, want only the name", columns)
+	}
+	if previous := changed.Previous(location); previous.Name == nil || *previous.Name != "Hall 1" || previous.Time != nil {
+		t.Fatalf("Previous = %+v, want only the old name", previous)
+	}
+	if nothing := (&LocationChanges{Time: ptr("9-5")}).Changed(location); len(nothing.Columns()) != 0 {
+		t.Fatalf("Changed = %+v, want nothing", nothing)
+	}
+}
@@ -0,0 +1,60 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Scopes a client of "Login with Compass" can ask for, each one unlocks a set of claims
+const (
+	ScopeOpenID     = "openid"
+	ScopeEmail      = "email"
+	ScopeProfile    = "profile"
+	ScopeRollNumber = "roll_number"
+)
+
+var OIDCScopeDescriptions = map[string]string{
+	ScopeOpenID:     "Know who you are on Compass",
+	ScopeEmail:      "Your IITK email address",
+	ScopeProfile:    "Your name, department and course",
+	ScopeRollNumber: "Your roll number",
+}
+
+// OAuthClient is an app registered for "Login with Compass"
+type OAuthClient struct {
+	ClientID     string     `gorm:"primaryKey" json:"clientId"`
+	SecretHash   string     `json:"-"` // empty for public clients (SPAs, mobile), they rely on PKCE only
+	Name         string     `gorm:"not null" json:"name"`
+	RedirectURIs []string   `gorm:"serializer:json" json:"redirectUris"` // exact match only
+	Scopes       []string   `gorm:"serializer:json" json:"scopes"`       // the most a client may ask for
+	CreatedBy    uuid.UUID  `gorm:"type:uuid" json:"createdBy"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	UpdatedAt    time.Time  `json:"-"`
+	DisabledAt   *time.Time `json:"disabledAt"`
+}
+
+// OAuthConsent remembers the scopes a user allowed to a client, the consent screen is skipped next time
+type OAuthConsent struct {
+	UserID    uuid.UUID    `gorm:"type:uuid;primaryKey" json:"-"`
+	ClientID  string       `gorm:"primaryKey" json:"clientId"`
+	Scopes    []string     `gorm:"serializer:json" json:"scopes"`
+	CreatedAt time.Time    `json:"createdAt"`
+	UpdatedAt time.Time    `json:"updatedAt"`
+	User      *User        `gorm:"foreignKey:UserID;references:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Client    *OAuthClient `gorm:"foreignKey:ClientID;references:ClientID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"client,omitempty"`
+}
+
+// OAuthCode is an authorization code, single use and short lived, only the hash is stored
+type OAuthCode struct {
+	CodeHash      string    `gorm:"primaryKey"`
+	ClientID      string    `gorm:"not null"`
+	UserID        uuid.UUID `gorm:"type:uuid;not null"`
+	RedirectURI   string
+	Scopes        []string `gorm:"serializer:json"`
+	Nonce         string
+	CodeChallenge string // PKCE, S256 only
+	AuthTime      time.Time
+	ExpiresAt     time.Time `gorm:"index"`
+	User          *User     `gorm:"foreignKey:UserID;references:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
@@ -0,0 +1,119 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Named permissions checked by middleware.Require
+const (
+	// Privileged, granted through roles like admin or coordinator
+	PermNoticePublish   = "notice.publish"
+	PermLocationApprove = "location.approve"
+	PermReviewModerate  = "review.moderate"
+	PermUserManage      = "user.manage"
+	PermRoleManage      = "role.manage"
+	PermLogsView        = "logs.view"
+	PermClientManage    = "client.manage" // register apps for "Login with Compass"
+
+	// Regular user capabilities
+	PermReviewCreate       = "review.create"
+	PermLocationContribute = "location.contribute"
+	PermDirectoryView      = "directory.view" // student search
+	PermStudentProfile     = "profile.student" // OA verified profile, listed in the student search
+	PermBookmarkManage     = "bookmark.manage"
+)
+
+// Built in role names, user, bot, admin and visitor map to the legacy Role values on the user
+const (
+	RoleNameUser        = "user"
+	RoleNameBot         = "bot"
+	RoleNameAdmin       = "admin"
+	RoleNameSuperAdmin  = "super_admin"
+	RoleNameCoordinator = "coordinator" // club coordinators, can publish notices
+	RoleNameVisitor     = "visitor"     // guests, browse and bookmark only
+)
+
+type Permission struct {
+	Name        string `gorm:"primaryKey" json:"name"`
+	Description string `json:"description"`
+}
+
+// AccessRole is a named group of permissions stored in the database.
+// (Role is already taken by the legacy integer role on the user)
+type AccessRole struct {
+	Name        string       `gorm:"primaryKey" json:"name"`
+	Description string       `json:"description"`
+	Permissions []Permission `gorm:"many2many:role_permissions;" json:"permissions"`
+}
+
+// RoleAssignment gives an extra role to a user, on top of the base role from User.Role
+type RoleAssignment struct {
+	UserID     uuid.UUID   `gorm:"type:uuid;primaryKey" json:"userId"`
+	RoleName   string      `gorm:"primaryKey" json:"role"`
+	AssignedBy uuid.UUID   `gorm:"type:uuid" json:"assignedBy"`
+	CreatedAt  time.Time   `json:"createdAt"`
+	User       *User       `gorm:"foreignKey:UserID;references:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Role       *AccessRole `gorm:"foreignKey:RoleName;references:Name;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+// PermissionDescriptions lists every permission known to the code, seeded at start up
+var PermissionDescriptions = map[string]string{
+	PermNoticePublish:      "Publish notices on the noticeboard",
+	PermLocationApprove:    "Approve or reject contributed locations",
+	PermReviewModerate:     "Moderate flagged reviews and uploaded images",
+	PermUserManage:         "Manage user accounts",
+	PermRoleManage:         "Assign roles to users",
+	PermLogsView:           "View system and audit logs",
+	PermClientManage:       "Register and disable OpenID Connect clients",
+	PermReviewCreate:       "Write reviews",
+	PermLocationContribute: "Contribute new locations",
+	PermDirectoryView:      "View the student search directory",
+	PermStudentProfile:     "Keep a verified student profile",
+	PermBookmarkManage:     "Bookmark locations",
+}
+
+var userPermissions = []string{PermReviewCreate, PermLocationContribute, PermDirectoryView, PermStudentProfile, PermBookmarkManage}
+
+// Visitors are not students, no reviews, contributions or student search
+var visitorPermissions = []string{PermBookmarkManage}
+
+// DefaultRoles are created at start up when missing, existing roles only get the permissions new to the code
+var DefaultRoles = map[string][]string{
+	RoleNameUser:        userPermissions,
+	RoleNameBot:         userPermissions, // bots passed every user check with the legacy role >= UserRole
+	RoleNameVisitor:     visitorPermissions,
+	RoleNameCoordinator: {PermNoticePublish},
+	RoleNameAdmin: append([]string{
+		PermNoticePublish, PermLocationApprove, PermReviewModerate, PermUserManage, PermLogsView,
+	}, userPermissions...),
+	RoleNameSuperAdmin: append([]string{
+		PermNoticePublish, PermLocationApprove, PermReviewModerate, PermUserManage, PermLogsView, PermRoleManage, PermClientManage,
+	}, userPermissions...),
+}
+
+// IsPrivileged tells if the permission is beyond the regular user capabilities,
+// such permissions need a session which passed the second factor.
+func IsPrivileged(permission string) bool {
+	for _, p := range userPermissions {
+		if p == permission {
+			return false
+		}
+	}
+	return true
+}
+
+// BaseRoleName maps the legacy integer role to the role stored in the database
+func (r Role) BaseRoleName() string {
+	switch {
+	case r >= AdminRole:
+		return RoleNameAdmin
+	case r >= Bot:
+		return RoleNameBot
+	case r >= UserRole:
+		return RoleNameUser
+	default:
+		return RoleNameVisitor
+	}
+}
@@ -0,0 +1,11 @@
+package model
+
+import "time"
+
+// RateLimit is an entry of the shared rate limit store (ratelimit.store: database)
+type RateLimit struct {
+	Key         string `gorm:"primaryKey"` // <limiter>:<email / ip / user id>
+	Failures    int
+	LastFailure time.Time `gorm:"index"`
+	LockedUntil time.Time
+}
@@ -0,0 +1,27 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session is one logged in device. Every refresh of the access token rotates
+// the refresh token, the row only keeps the hash of the current one (and the
+// previous one, to survive parallel refresh requests from the same browser).
+// A row is a single refresh token family, revoking it logs that device out.
+type Session struct {
+	SessionID         uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"sessionId"`
+	UserID            uuid.UUID  `gorm:"type:uuid;not null;index" json:"-"`
+	TokenHash         string     `gorm:"index" json:"-"` // sha256 of the current refresh token id
+	PreviousTokenHash string     `json:"-"`
+	RotatedAt         time.Time  `json:"-"`
+	UserAgent         string     `json:"userAgent"`
+	IP                string     `json:"ip"`
+	CreatedAt         time.Time  `json:"createdAt"`
+	LastUsedAt        time.Time  `json:"lastUsedAt"`
+	ExpiresAt         time.Time  `gorm:"index" json:"expiresAt"`
+	RevokedAt         *time.Time `gorm:"index" json:"-"`
+	MFAVerified       bool       `json:"mfaVerified"` // second factor was checked while logging in on this device
+	User              *User      `gorm:"foreignKey:UserID;references:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
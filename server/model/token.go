@@ -0,0 +1,30 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Purposes of the one time tokens, a user has at most one pending token per purpose
+const (
+	PurposeVerifyEmail   = "verify_email"
+	PurposeResetPassword = "reset_password"
+	PurposeChangeEmail   = "change_email"
+	PurposeMagicLogin    = "magic_login"
+)
+
+// OneTimeToken is a code or link sent over mail. Only the hash is stored,
+// the token is gone once consumed, expired or guessed wrong too many times.
+type OneTimeToken struct {
+	ID         uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	UserID     uuid.UUID `gorm:"type:uuid;not null;index:idx_token_user_purpose"`
+	Purpose    string    `gorm:"type:varchar(20);not null;index:idx_token_user_purpose;check:purpose IN ('verify_email','reset_password','change_email','magic_login')"`
+	TokenHash  string    `gorm:"not null"`
+	Email      string    // the new address, only for change_email
+	Attempts   int       `gorm:"default:0"` // wrong guesses
+	ExpiresAt  time.Time `gorm:"index"`
+	ConsumedAt *time.Time
+	CreatedAt  time.Time
+	User       *User `gorm:"foreignKey:UserID;references:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
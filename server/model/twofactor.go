@@ -0,0 +1,17 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RecoveryCode is a one time code to pass the second factor when the authenticator app is lost
+type RecoveryCode struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	CodeHash  string    `gorm:"not null"` // sha256 of the code, the code itself is shown only once
+	UsedAt    *time.Time
+	CreatedAt time.Time
+	User      *User `gorm:"foreignKey:UserID;references:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
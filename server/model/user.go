@@ -13,7 +13,8 @@ const (
 	AdminRole Role = 100 // "admin"
 	Bot       Role = 99  // "bot"
 	UserRole  Role = 50  // "user"
-	// TODO: add roles like Super Admin, Visitors
+	// Parents, alumni, guests: non iitk emails, the account expires
+	VisitorRole Role = 10 // "visitor"
 )
 
 type User struct {
@@ -22,13 +23,23 @@ type User struct {
 	DeletedAt gorm.DeletedAt `gorm:"index"`
 
 	// Internal fields
-	UserID            uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
-	Email             string    `gorm:"unique" json:"email"`
-	ProfilePic        bool      `json:"profilePic"`
-	Password          string    `json:"password"`
-	IsVerified        bool      `json:"-"`
-	VerificationToken string    `json:"-"` //erased after verification
-	Role              Role      `json:"role" gorm:"type:int;"`
+	UserID     uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	Email      string    `gorm:"unique" json:"email"`
+	ProfilePic bool      `json:"profilePic"`
+	Password   string    `json:"-"`
+	IsVerified bool      `json:"-"`
+	Role       Role      `json:"role" gorm:"type:int;"`
+
+	// Set by an admin, a suspended user can not login or use any existing session
+	SuspendedAt   *time.Time `json:"suspendedAt"`
+	SuspendReason string     `json:"suspendReason,omitempty"`
+	// Only for visitors, the account can not be used after this
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+
+	// Two factor authentication (TOTP), the secret is set on setup and enabled only after confirmation
+	TOTPSecret      string `json:"-"`
+	TOTPEnabled     bool   `json:"twoFactorEnabled"`
+	TOTPLastCounter int64  `json:"-"` // last accepted time step, a code can not be used twice
 
 	// Search Profile
 	Profile Profile `gorm:"foreignKey:UserID;references:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"profile"`
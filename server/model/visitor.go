@@ -0,0 +1,22 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// VisitorInvite lets people without an allowed email domain sign up as visitors.
+// Created by the admins, only the hash of the code is kept.
+type VisitorInvite struct {
+	ID          uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	CodeHash    string     `gorm:"uniqueIndex;not null" json:"-"`
+	Note        string     `json:"note"` // who it is for, e.g. "Convocation guests"
+	MaxUses     int        `json:"maxUses"`
+	Uses        int        `json:"uses"`
+	AccountDays int        `json:"accountDays"` // life of the visitor accounts made with it
+	ExpiresAt   time.Time  `json:"expiresAt"`   // the code can not be used after this
+	CreatedBy   uuid.UUID  `gorm:"type:uuid" json:"createdBy"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	RevokedAt   *time.Time `json:"revokedAt"`
+}
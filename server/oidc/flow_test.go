@@ -0,0 +1,245 @@
+//go:build integration
+
+// End to end "Login with Compass" against the real database, needs the postgres and rabbitmq of docker-compose:
+//
+//	go test -tags integration ./oidc
+package oidc
+
+import (
+	"compass/connections"
+	"compass/middleware"
+	"compass/model"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+)
+
+const testRedirectURI = "http://localhost:9090/callback"
+
+type testSetup struct {
+	server   *httptest.Server
+	client   *http.Client
+	user     model.User
+	clientID string
+	session  string // auth_token cookie of the user
+}
+
+func setup(t *testing.T) *testSetup {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	// Temporary signing key, the configured ones live next to the binary
+	viper.Set("jwt.keys_dir", "")
+	viper.Set("jwt.keys", nil)
+	viper.Set("jwt.signing_kid", "")
+
+	r := gin.New()
+	Router(r)
+	s := &testSetup{
+		server: httptest.NewServer(r),
+		// The redirects are the answers under test, they are not followed
+		client: &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }},
+	}
+	viper.Set("oidc.issuer", s.server.URL)
+	t.Cleanup(s.server.Close)
+
+	s.user = model.User{
+		Email:      "oidc-" + uuid.NewString()[:8] + "@iitk.ac.in",
+		IsVerified: true,
+		Role:       model.UserRole,
+		Profile:    model.Profile{Name: "Test User", RollNo: "230001"},
+	}
+	if err := connections.DB.Create(&s.user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	s.clientID = "test-" + uuid.NewString()
+	if err := connections.DB.Create(&model.OAuthClient{
+		ClientID:     s.clientID,
+		Name:         "Test app",
+		RedirectURIs: []string{testRedirectURI},
+		Scopes:       []string{model.ScopeOpenID, model.ScopeEmail, model.ScopeProfile},
+	}).Error; err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+	t.Cleanup(func() {
+		connections.DB.Where("client_id = ?", s.clientID).Delete(&model.OAuthClient{})
+		connections.DB.Unscoped().Where("user_id = ?", s.user.UserID).Delete(&model.Profile{})
+		connections.DB.Unscoped().Where("user_id = ?", s.user.UserID).Delete(&model.User{})
+	})
+
+	token, err := middleware.GenerateAccessToken(s.user.UserID, uuid.New())
+	if err != nil {
+		t.Fatalf("session token: %v", err)
+	}
+	s.session = token
+	return s
+}
+
+// authorize runs the browser part of the flow and returns the code handed to the client
+func (s *testSetup) authorize(t *testing.T, challenge string) string {
+	t.Helper()
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {s.clientID},
+		"redirect_uri":          {testRedirectURI},
+		"scope":                 {"openid email profile"},
+		"state":                 {"xyz"},
+		"nonce":                 {"n-0S6_WzA2Mj"},
+		"code_challenge":        {challenge},
+		"code_challenge_method": {"S256"},
+	}
+	resp, err := s.client.Get(s.server.URL + "/api/oidc/authorize?" + query.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound || !strings.Contains(resp.Header.Get("Location"), "/oauth/consent?") {
+		t.Fatalf("authorize: %d %q, want a redirect to the consent page", resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	// The consent page posts the same query back along with the answer
+	var consent ConsentRequest
+	consent.AuthorizeRequest = AuthorizeRequest{
+		ResponseType:        query.Get("response_type"),
+		ClientID:            query.Get("client_id"),
+		RedirectURI:         query.Get("redirect_uri"),
+		Scope:               query.Get("scope"),
+		State:               query.Get("state"),
+		Nonce:               query.Get("nonce"),
+		CodeChallenge:       query.Get("code_challenge"),
+		CodeChallengeMethod: query.Get("code_challenge_method"),
+	}
+	consent.Approve = true
+	body, _ := json.Marshal(consent)
+	req, _ := http.NewRequest(http.MethodPost, s.server.URL+"/api/oidc/consent", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: s.session})
+	var answer struct {
+		Redirect string `json:"redirect"`
+		Error    string `json:"error"`
+	}
+	if status := s.doJSON(t, req, &answer); status != http.StatusOK {
+		t.Fatalf("consent: %d %s", status, answer.Error)
+	}
+	redirect, err := url.Parse(answer.Redirect)
+	if err != nil || !strings.HasPrefix(answer.Redirect, testRedirectURI) {
+		t.Fatalf("consent redirect %q, want the redirect uri", answer.Redirect)
+	}
+	if redirect.Query().Get("state") != "xyz" || redirect.Query().Get("code") == "" {
+		t.Fatalf("consent redirect %q, want the state and a code", answer.Redirect)
+	}
+	return redirect.Query().Get("code")
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	IDToken     string `json:"id_token"`
+	Scope       string `json:"scope"`
+	Error       string `json:"error"`
+}
+
+func (s *testSetup) token(t *testing.T, clientID, code, verifier string) (int, tokenResponse) {
+	t.Helper()
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {testRedirectURI},
+		"client_id":     {clientID},
+		"code_verifier": {verifier},
+	}
+	req, _ := http.NewRequest(http.MethodPost, s.server.URL+"/api/oidc/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var tokens tokenResponse
+	return s.doJSON(t, req, &tokens), tokens
+}
+
+func (s *testSetup) doJSON(t *testing.T, req *http.Request, v any) int {
+	t.Helper()
+	resp, err := s.client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatalf("%s %s: %d, %v", req.Method, req.URL.Path, resp.StatusCode, err)
+	}
+	return resp.StatusCode
+}
+
+func pkce() (verifier string, challenge string) {
+	verifier = strings.Repeat("v", 20) + uuid.NewString()
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	s := setup(t)
+	verifier, challenge := pkce()
+	code := s.authorize(t, challenge)
+
+	if status, tokens := s.token(t, s.clientID, code, verifier+"x"); status != http.StatusBadRequest || tokens.Error != "invalid_grant" {
+		t.Fatalf("wrong verifier: %d %q, want invalid_grant", status, tokens.Error)
+	}
+	// A failed PKCE check burns the code, the client starts over
+	code = s.authorize(t, challenge)
+	status, tokens := s.token(t, s.clientID, code, verifier)
+	if status != http.StatusOK {
+		t.Fatalf("token: %d %q", status, tokens.Error)
+	}
+
+	claims := jwt.MapClaims{}
+	if _, err := middleware.ParseToken(tokens.IDToken, claims, jwt.WithIssuer(s.server.URL), jwt.WithAudience(s.clientID)); err != nil {
+		t.Fatalf("id token: %v", err)
+	}
+	if claims["sub"] != s.user.UserID.String() || claims["nonce"] != "n-0S6_WzA2Mj" || claims["email"] != s.user.Email {
+		t.Fatalf("id token claims %v", claims)
+	}
+	if _, ok := claims["roll_number"]; ok {
+		t.Fatal("roll_number was not asked for")
+	}
+
+	if status, replay := s.token(t, s.clientID, code, verifier); status != http.StatusBadRequest || replay.Error != "invalid_grant" {
+		t.Fatalf("replayed code: %d %q, want invalid_grant", status, replay.Error)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, s.server.URL+"/api/oidc/userinfo", nil)
+	req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	userinfo := map[string]any{}
+	if status := s.doJSON(t, req, &userinfo); status != http.StatusOK {
+		t.Fatalf("userinfo: %d %v", status, userinfo)
+	}
+	if userinfo["sub"] != s.user.UserID.String() || userinfo["name"] != "Test User" || userinfo["email_verified"] != true {
+		t.Fatalf("userinfo %v", userinfo)
+	}
+
+	// The session token is not an access token of a client
+	req, _ = http.NewRequest(http.MethodGet, s.server.URL+"/api/oidc/userinfo", nil)
+	req.Header.Set("Authorization", "Bearer "+s.session)
+	if status := s.doJSON(t, req, &userinfo); status != http.StatusUnauthorized {
+		t.Fatalf("userinfo with a session token: %d, want 401", status)
+	}
+}
+
+func TestCodeOfAnotherClient(t *testing.T) {
+	s := setup(t)
+	other := setup(t)
+	verifier, challenge := pkce()
+	code := s.authorize(t, challenge)
+
+	if status, tokens := other.token(t, other.clientID, code, verifier); status != http.StatusBadRequest || tokens.Error != "invalid_grant" {
+		t.Fatalf("code of another client: %d %q, want invalid_grant", status, tokens.Error)
+	}
+	// Still good for the client it was issued to
+	if status, tokens := s.token(t, s.clientID, code, verifier); status != http.StatusOK {
+		t.Fatalf("token after another client tried the code: %d %q", status, tokens.Error)
+	}
+}
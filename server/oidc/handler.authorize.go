@@ -0,0 +1,138 @@
+package oidc
+
+import (
+	"compass/connections"
+	"compass/model"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+	"gorm.io/gorm/clause"
+)
+
+// Entry point of the flow, the browser is sent on to the consent page of the frontend,
+// which talks to the consent api below (after making the user login if needed)
+func authorizeHandler(c *gin.Context) {
+	var req AuthorizeRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "Invalid request format"})
+		return
+	}
+	if _, _, authErr := validateAuthorize(req); authErr != nil {
+		if authErr.redirect {
+			c.Redirect(http.StatusFound, errorRedirect(req, authErr))
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": authErr.code, "error_description": authErr.description})
+		return
+	}
+	c.Redirect(http.StatusFound, fmt.Sprintf("%s/oauth/consent?%s", viper.GetString("frontend_url"), c.Request.URL.RawQuery))
+}
+
+// Data for the consent screen. If the user already allowed these scopes the code is issued right away
+// and only the redirect is returned.
+func consentDetailsHandler(c *gin.Context) {
+	var req AuthorizeRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	client, scopes, authErr := validateAuthorize(req)
+	if authErr != nil {
+		if authErr.redirect {
+			c.JSON(http.StatusOK, gin.H{"redirect": errorRedirect(req, authErr)})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": authErr.description})
+		return
+	}
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	if req.Prompt != "consent" {
+		var consent model.OAuthConsent
+		err := connections.DB.Where("user_id = ? AND client_id = ?", userID, client.ClientID).Limit(1).Find(&consent).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if consent.ClientID != "" && containsAll(consent.Scopes, scopes) {
+			redirect, err := issueCode(req, userID, scopes, authTime(c))
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue the code"})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"redirect": redirect})
+			return
+		}
+	}
+
+	described := make([]gin.H, 0, len(scopes))
+	for _, scope := range scopes {
+		described = append(described, gin.H{"scope": scope, "description": model.OIDCScopeDescriptions[scope]})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"client": gin.H{"clientId": client.ClientID, "name": client.Name},
+		"scopes": described,
+	})
+}
+
+// Answer of the consent screen, returns where the browser should go next
+func consentHandler(c *gin.Context) {
+	var req ConsentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	client, scopes, authErr := validateAuthorize(req.AuthorizeRequest)
+	if authErr != nil {
+		if authErr.redirect {
+			c.JSON(http.StatusOK, gin.H{"redirect": errorRedirect(req.AuthorizeRequest, authErr)})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": authErr.description})
+		return
+	}
+	if !req.Approve {
+		c.JSON(http.StatusOK, gin.H{"redirect": errorRedirect(req.AuthorizeRequest, &authorizeError{code: "access_denied", description: "The user denied the request"})})
+		return
+	}
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	if err := connections.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "client_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"scopes", "updated_at"}),
+	}).Create(&model.OAuthConsent{UserID: userID, ClientID: client.ClientID, Scopes: scopes}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save the consent"})
+		return
+	}
+	redirect, err := issueCode(req.AuthorizeRequest, userID, scopes, authTime(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue the code"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"redirect": redirect})
+}
+
+// time the user logged in on this device, the start of the session
+func authTime(c *gin.Context) time.Time {
+	var session model.Session
+	if sessionID, ok := c.Get("sessionID"); ok {
+		if err := connections.DB.Select("created_at").Where("session_id = ?", sessionID).Limit(1).Find(&session).Error; err == nil && !session.CreatedAt.IsZero() {
+			return session.CreatedAt
+		}
+	}
+	return time.Now()
+}
+
+func containsAll(granted []string, requested []string) bool {
+	for _, scope := range requested {
+		if !slices.Contains(granted, scope) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,129 @@
+package oidc
+
+import (
+	"compass/connections"
+	"compass/model"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Registered apps, for the admins
+func listClientsHandler(c *gin.Context) {
+	var clients []model.OAuthClient
+	if err := connections.DB.Order("created_at DESC").Find(&clients).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch clients"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"clients": clients})
+}
+
+// Registers an app, the secret (confidential clients only) is shown only once
+func createClientHandler(c *gin.Context) {
+	var req CreateClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	scopes := req.Scopes
+	if len(scopes) == 0 {
+		for scope := range model.OIDCScopeDescriptions {
+			scopes = append(scopes, scope)
+		}
+	}
+	for _, scope := range scopes {
+		if _, known := model.OIDCScopeDescriptions[scope]; !known {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown scope " + scope})
+			return
+		}
+	}
+	if !slices.Contains(scopes, model.ScopeOpenID) {
+		scopes = append(scopes, model.ScopeOpenID)
+	}
+	slices.Sort(scopes)
+
+	clientID, err := randomToken(16)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create client"})
+		return
+	}
+	client := model.OAuthClient{
+		ClientID:     clientID,
+		Name:         req.Name,
+		RedirectURIs: req.RedirectURIs,
+		Scopes:       scopes,
+		CreatedBy:    c.MustGet("userID").(uuid.UUID),
+	}
+	var secret string
+	if req.Confidential {
+		if secret, err = randomToken(32); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create client"})
+			return
+		}
+		client.SecretHash = hashSecret(secret)
+	}
+	if err := connections.DB.Create(&client).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create client"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"client": client, "clientSecret": secret})
+}
+
+// Disabling stops new logins, the consents are dropped so re-enabling asks the users again
+func disableClientHandler(c *gin.Context) {
+	clientID := c.Param("id")
+	if err := connections.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.OAuthClient{}).Where("client_id = ? AND disabled_at IS NULL", clientID).Update("disabled_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := tx.Where("client_id = ?", clientID).Delete(&model.OAuthConsent{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("client_id = ?", clientID).Delete(&model.OAuthCode{}).Error
+	}); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable client"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Client disabled"})
+}
+
+// Apps the user has allowed to login with Compass
+func listConsentsHandler(c *gin.Context) {
+	var consents []model.OAuthConsent
+	if err := connections.DB.
+		Preload("Client", func(db *gorm.DB) *gorm.DB { return db.Select("client_id", "name") }).
+		Where("user_id = ?", c.MustGet("userID").(uuid.UUID)).
+		Find(&consents).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch apps"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"consents": consents})
+}
+
+// The app will have to ask again, tokens already issued live till they expire
+func revokeConsentHandler(c *gin.Context) {
+	result := connections.DB.
+		Where("user_id = ? AND client_id = ?", c.MustGet("userID").(uuid.UUID), c.Param("clientId")).
+		Delete(&model.OAuthConsent{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke access"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "App not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Access revoked"})
+}
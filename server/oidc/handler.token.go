@@ -0,0 +1,181 @@
+package oidc
+
+import (
+	"compass/connections"
+	"compass/middleware"
+	"compass/model"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+// Access tokens handed to the clients, only accepted by the userinfo endpoint
+type accessTokenClaims struct {
+	Scope    string `json:"scope"`
+	ClientID string `json:"client_id"`
+	jwt.RegisteredClaims
+}
+
+// Errors of the token endpoint follow RFC 6749 5.2
+func tokenError(c *gin.Context, status int, code string, description string) {
+	c.Header("Cache-Control", "no-store")
+	c.JSON(status, gin.H{"error": code, "error_description": description})
+}
+
+// Exchanges the authorization code for the id token and the access token
+func tokenHandler(c *gin.Context) {
+	var req TokenRequest
+	if err := c.ShouldBind(&req); err != nil {
+		tokenError(c, http.StatusBadRequest, "invalid_request", "Invalid request format")
+		return
+	}
+	if req.GrantType != "authorization_code" {
+		tokenError(c, http.StatusBadRequest, "unsupported_grant_type", "Only authorization_code is supported")
+		return
+	}
+
+	// client_secret_basic or client_secret_post, public clients send only the id
+	clientID, secret, basic := c.Request.BasicAuth()
+	if !basic {
+		clientID, secret = req.ClientID, req.ClientSecret
+	}
+	var client model.OAuthClient
+	if err := connections.DB.Where("client_id = ? AND disabled_at IS NULL", clientID).First(&client).Error; err != nil {
+		tokenError(c, http.StatusUnauthorized, "invalid_client", "Unknown client")
+		return
+	}
+	if client.SecretHash != "" && subtle.ConstantTimeCompare([]byte(client.SecretHash), []byte(hashSecret(secret))) != 1 {
+		tokenError(c, http.StatusUnauthorized, "invalid_client", "Invalid client credentials")
+		return
+	}
+
+	// The code is deleted on the first use, a replayed code finds nothing.
+	// Only the client it was issued to may use it up, another client can not burn it.
+	var code model.OAuthCode
+	result := connections.DB.Clauses(clause.Returning{}).
+		Where("code_hash = ? AND client_id = ?", hashSecret(req.Code), client.ClientID).
+		Delete(&code)
+	if result.Error != nil {
+		tokenError(c, http.StatusInternalServerError, "server_error", "Database error")
+		return
+	}
+	if result.RowsAffected == 0 || time.Now().After(code.ExpiresAt) {
+		tokenError(c, http.StatusBadRequest, "invalid_grant", "Invalid or expired code")
+		return
+	}
+	if code.RedirectURI != req.RedirectURI {
+		tokenError(c, http.StatusBadRequest, "invalid_grant", "Code was issued to another redirect uri")
+		return
+	}
+	if !verifyPKCE(req.CodeVerifier, code.CodeChallenge) {
+		tokenError(c, http.StatusBadRequest, "invalid_grant", "Invalid code verifier")
+		return
+	}
+
+	user, err := loadUser(connections.DB, code.UserID.String())
+	if err != nil {
+		tokenError(c, http.StatusBadRequest, "invalid_grant", "User is no longer active")
+		return
+	}
+
+	now := time.Now()
+	idClaims := jwt.MapClaims{
+		"iss":       issuer(),
+		"aud":       client.ClientID,
+		"iat":       now.Unix(),
+		"exp":       now.Add(idTokenExpiry).Unix(),
+		"auth_time": code.AuthTime.Unix(),
+	}
+	if code.Nonce != "" {
+		idClaims["nonce"] = code.Nonce
+	}
+	for key, value := range userClaims(user, code.Scopes) {
+		idClaims[key] = value
+	}
+	idToken, err := middleware.SignToken(idClaims)
+	if err != nil {
+		tokenError(c, http.StatusInternalServerError, "server_error", "Failed to sign the token")
+		return
+	}
+
+	scope := strings.Join(code.Scopes, " ")
+	accessToken, err := middleware.SignToken(accessTokenClaims{
+		Scope:    scope,
+		ClientID: client.ClientID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Issuer:    issuer(),
+			Subject:   user.UserID.String(),
+			Audience:  jwt.ClaimStrings{userinfoEndpoint()},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(accessTokenExpiry)),
+		},
+	})
+	if err != nil {
+		tokenError(c, http.StatusInternalServerError, "server_error", "Failed to sign the token")
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+	c.JSON(http.StatusOK, gin.H{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(accessTokenExpiry.Seconds()),
+		"id_token":     idToken,
+		"scope":        scope,
+	})
+}
+
+// Claims of the user behind the access token, limited to the granted scopes
+func userinfoHandler(c *gin.Context) {
+	tokenString, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !found {
+		c.Header("WWW-Authenticate", `Bearer`)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_request", "error_description": "Bearer token required"})
+		return
+	}
+	claims := &accessTokenClaims{}
+	if _, err := middleware.ParseToken(tokenString, claims, jwt.WithAudience(userinfoEndpoint()), jwt.WithIssuer(issuer())); err != nil {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_token", "error_description": "Invalid or expired token"})
+		return
+	}
+	user, err := loadUser(connections.DB, claims.Subject)
+	if err != nil {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_token", "error_description": "User is no longer active"})
+		return
+	}
+	c.JSON(http.StatusOK, userClaims(user, strings.Fields(claims.Scope)))
+}
+
+// OpenID Connect discovery document, served at <issuer>/.well-known/openid-configuration
+func discoveryHandler(c *gin.Context) {
+	scopes := make([]string, 0, len(model.OIDCScopeDescriptions))
+	for scope := range model.OIDCScopeDescriptions {
+		scopes = append(scopes, scope)
+	}
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{
+		"issuer":                                issuer(),
+		"authorization_endpoint":                issuer() + "/api/oidc/authorize",
+		"token_endpoint":                        issuer() + "/api/oidc/token",
+		"userinfo_endpoint":                     userinfoEndpoint(),
+		"jwks_uri":                              issuer() + "/.well-known/jwks.json",
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{"authorization_code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{jwt.SigningMethodES256.Alg(), jwt.SigningMethodEdDSA.Alg()},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"scopes_supported":                      scopes,
+		"claims_supported":                      []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "email", "email_verified", "name", "department", "course", "roll_number"},
+	})
+}
@@ -0,0 +1,36 @@
+package oidc
+
+// Query of /authorize, the consent api gets the same fields back from the frontend
+type AuthorizeRequest struct {
+	ResponseType        string `form:"response_type" json:"response_type"`
+	ClientID            string `form:"client_id" json:"client_id"`
+	RedirectURI         string `form:"redirect_uri" json:"redirect_uri"`
+	Scope               string `form:"scope" json:"scope"`
+	State               string `form:"state" json:"state"`
+	Nonce               string `form:"nonce" json:"nonce"`
+	CodeChallenge       string `form:"code_challenge" json:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method"`
+	Prompt              string `form:"prompt" json:"prompt"` // "consent" forces the consent screen
+}
+
+type ConsentRequest struct {
+	AuthorizeRequest
+	Approve bool `json:"approve"`
+}
+
+// Form body of /token, the client may authenticate through basic auth instead
+type TokenRequest struct {
+	GrantType    string `form:"grant_type" binding:"required"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+	CodeVerifier string `form:"code_verifier"`
+}
+
+type CreateClientRequest struct {
+	Name         string   `json:"name" binding:"required"`
+	RedirectURIs []string `json:"redirectUris" binding:"required,min=1,dive,url"`
+	Scopes       []string `json:"scopes"`       // defaults to every scope
+	Confidential bool     `json:"confidential"` // server side apps get a secret, SPAs and mobile apps do not
+}
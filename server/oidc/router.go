@@ -0,0 +1,40 @@
+// "Login with Compass": OpenID Connect provider (authorization code + PKCE) for the other campus apps
+// Mounted on the auth server
+package oidc
+
+import (
+	"compass/middleware"
+	"compass/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+func Router(r *gin.Engine) {
+	r.GET("/.well-known/openid-configuration", discoveryHandler)
+
+	oidc := r.Group("/api/oidc")
+	{
+		oidc.GET("/authorize", authorizeHandler)
+		oidc.POST("/token", tokenHandler)
+		oidc.GET("/userinfo", userinfoHandler)
+		oidc.POST("/userinfo", userinfoHandler)
+		// Used by the consent page of the frontend
+		oidc.GET("/consent", middleware.UserAuthenticator, middleware.SessionOnly, middleware.NotImpersonating, consentDetailsHandler)
+		oidc.POST("/consent", middleware.UserAuthenticator, middleware.SessionOnly, consentHandler)
+	}
+	// Apps the user allowed
+	consents := r.Group("/api/oidc/consents")
+	{
+		consents.Use(middleware.UserAuthenticator, middleware.SessionOnly)
+		consents.GET("", listConsentsHandler)
+		consents.DELETE("/:clientId", revokeConsentHandler)
+	}
+	// Client registration, for the admins
+	clients := r.Group("/api/oidc/clients")
+	{
+		clients.Use(middleware.UserAuthenticator, middleware.Require(model.PermClientManage))
+		clients.GET("", listClientsHandler)
+		clients.POST("", createClientHandler)
+		clients.DELETE("/:id", disableClientHandler)
+	}
+}
@@ -0,0 +1,181 @@
+package oidc
+
+import (
+	"compass/connections"
+	"compass/model"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+const (
+	authCodeExpiry    = 2 * time.Minute
+	accessTokenExpiry = time.Hour
+	idTokenExpiry     = time.Hour
+)
+
+// issuer is the base url of the auth server, the discovery document lives under it
+func issuer() string {
+	return strings.TrimSuffix(viper.GetString("oidc.issuer"), "/")
+}
+
+// audience of the access tokens, they are only good for the userinfo endpoint
+func userinfoEndpoint() string {
+	return issuer() + "/api/oidc/userinfo"
+}
+
+func randomToken(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// PKCE S256: BASE64URL(SHA256(code_verifier)) == code_challenge
+func verifyPKCE(verifier string, challenge string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:]) == challenge
+}
+
+// authorizeError follows RFC 6749 4.1.2.1, only the ones with redirect set are sent back to the client,
+// a bad client or redirect uri must never redirect
+type authorizeError struct {
+	code        string
+	description string
+	redirect    bool
+}
+
+func (e *authorizeError) Error() string {
+	return e.code + ": " + e.description
+}
+
+// validateAuthorize checks the authorization request against the registered client
+func validateAuthorize(req AuthorizeRequest) (model.OAuthClient, []string, *authorizeError) {
+	var client model.OAuthClient
+	if err := connections.DB.Where("client_id = ? AND disabled_at IS NULL", req.ClientID).First(&client).Error; err != nil {
+		return client, nil, &authorizeError{code: "invalid_client", description: "Unknown client"}
+	}
+	if !slices.Contains(client.RedirectURIs, req.RedirectURI) {
+		return client, nil, &authorizeError{code: "invalid_request", description: "Redirect uri is not registered for the client"}
+	}
+	if req.ResponseType != "code" {
+		return client, nil, &authorizeError{code: "unsupported_response_type", description: "Only the code flow is supported", redirect: true}
+	}
+
+	scopes := strings.Fields(req.Scope)
+	if !slices.Contains(scopes, model.ScopeOpenID) {
+		return client, nil, &authorizeError{code: "invalid_scope", description: "The openid scope is required", redirect: true}
+	}
+	for _, scope := range scopes {
+		if _, known := model.OIDCScopeDescriptions[scope]; !known || !slices.Contains(client.Scopes, scope) {
+			return client, nil, &authorizeError{code: "invalid_scope", description: "Scope " + scope + " is not allowed", redirect: true}
+		}
+	}
+	slices.Sort(scopes)
+	scopes = slices.Compact(scopes)
+
+	// PKCE is mandatory for every client, plain is not accepted
+	if req.CodeChallengeMethod != "S256" || len(req.CodeChallenge) < 43 || len(req.CodeChallenge) > 128 {
+		return client, nil, &authorizeError{code: "invalid_request", description: "PKCE with S256 is required", redirect: true}
+	}
+	return client, scopes, nil
+}
+
+// clientRedirect builds the redirect back to the client with the given params and the state
+func clientRedirect(req AuthorizeRequest, params url.Values) string {
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	target, err := url.Parse(req.RedirectURI)
+	if err != nil {
+		return req.RedirectURI
+	}
+	query := target.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	target.RawQuery = query.Encode()
+	return target.String()
+}
+
+func errorRedirect(req AuthorizeRequest, e *authorizeError) string {
+	return clientRedirect(req, url.Values{"error": {e.code}, "error_description": {e.description}})
+}
+
+// issueCode stores the hash of a new authorization code and returns the redirect carrying it
+func issueCode(req AuthorizeRequest, userID uuid.UUID, scopes []string, authTime time.Time) (string, error) {
+	code, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	entry := model.OAuthCode{
+		CodeHash:      hashSecret(code),
+		ClientID:      req.ClientID,
+		UserID:        userID,
+		RedirectURI:   req.RedirectURI,
+		Scopes:        scopes,
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+		AuthTime:      authTime,
+		ExpiresAt:     time.Now().Add(authCodeExpiry),
+	}
+	if err := connections.DB.Create(&entry).Error; err != nil {
+		return "", err
+	}
+	return clientRedirect(req, url.Values{"code": {code}}), nil
+}
+
+// userClaims are the claims about the user unlocked by the granted scopes,
+// shared by the id token and the userinfo endpoint
+func userClaims(user model.User, scopes []string) map[string]interface{} {
+	claims := map[string]interface{}{"sub": user.UserID.String()}
+	if slices.Contains(scopes, model.ScopeEmail) {
+		claims["email"] = user.Email
+		claims["email_verified"] = user.IsVerified
+	}
+	if slices.Contains(scopes, model.ScopeProfile) {
+		setIfPresent(claims, "name", user.Profile.Name)
+		setIfPresent(claims, "department", user.Profile.Dept)
+		setIfPresent(claims, "course", user.Profile.Course)
+	}
+	// Filled from the OA data, so it is verified by the institute
+	if slices.Contains(scopes, model.ScopeRollNumber) {
+		setIfPresent(claims, "roll_number", user.Profile.RollNo)
+	}
+	return claims
+}
+
+func setIfPresent(claims map[string]interface{}, key string, value string) {
+	if value != "" {
+		claims[key] = value
+	}
+}
+
+// loadUser fetches an active user along with the profile, suspended users can not sign in to other apps either
+func loadUser(db *gorm.DB, userID string) (model.User, error) {
+	var user model.User
+	err := db.
+		Select("user_id", "email", "is_verified", "suspended_at").
+		Preload("Profile").
+		Where("user_id = ? AND suspended_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", userID, time.Now()).
+		First(&user).Error
+	return user, err
+}
@@ -0,0 +1,159 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Algorithms for the new hashes, the stored hash carries its own algorithm and parameters
+// ($2a$<cost>$... for bcrypt, $argon2id$v=19$m=,t=,p=$<salt>$<key> for argon2id),
+// so the old hashes keep working when the config changes and get upgraded on the next login.
+const (
+	Bcrypt   = "bcrypt"
+	Argon2id = "argon2id"
+)
+
+var ErrUnknownHash = errors.New("unknown password hash format")
+
+type Hasher struct {
+	Algorithm  string
+	BcryptCost int
+	// argon2id parameters
+	Memory  uint32 // KiB
+	Time    uint32
+	Threads uint8
+}
+
+const (
+	argonSaltLength = 16
+	argonKeyLength  = 32
+)
+
+var hasher *Hasher
+
+// LoadHasher reads the hasher from the config, called once at startup before any password is hashed
+func LoadHasher() error {
+	viper.SetDefault("password.hash.algorithm", Bcrypt)
+	viper.SetDefault("password.hash.bcrypt_cost", 12)
+	viper.SetDefault("password.hash.argon2.memory", 64*1024)
+	viper.SetDefault("password.hash.argon2.time", 3)
+	viper.SetDefault("password.hash.argon2.threads", 2)
+	h := &Hasher{
+		Algorithm:  viper.GetString("password.hash.algorithm"),
+		BcryptCost: viper.GetInt("password.hash.bcrypt_cost"),
+		Memory:     viper.GetUint32("password.hash.argon2.memory"),
+		Time:       viper.GetUint32("password.hash.argon2.time"),
+	}
+	if h.Algorithm != Bcrypt && h.Algorithm != Argon2id {
+		return fmt.Errorf("unknown password.hash.algorithm %q", h.Algorithm)
+	}
+	if h.BcryptCost < bcrypt.MinCost || h.BcryptCost > bcrypt.MaxCost {
+		return fmt.Errorf("password.hash.bcrypt_cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	// argon2.IDKey panics on a zero time or threads
+	threads := viper.GetUint("password.hash.argon2.threads")
+	if threads < 1 || threads > 255 {
+		return errors.New("password.hash.argon2.threads must be between 1 and 255")
+	}
+	h.Threads = uint8(threads)
+	if h.Time < 1 {
+		return errors.New("password.hash.argon2.time must be at least 1")
+	}
+	if h.Memory < 8*uint32(h.Threads) {
+		return fmt.Errorf("password.hash.argon2.memory must be at least %d KiB (8 per thread)", 8*uint32(h.Threads))
+	}
+	hasher = h
+	return nil
+}
+
+// DefaultHasher is the hasher loaded by LoadHasher
+func DefaultHasher() *Hasher {
+	return hasher
+}
+
+// Hash with the current algorithm and parameters
+func (h *Hasher) Hash(password string) (string, error) {
+	if h.Algorithm == Argon2id {
+		salt := make([]byte, argonSaltLength)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		key := argon2.IDKey([]byte(password), salt, h.Time, h.Memory, h.Threads, argonKeyLength)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, h.Memory, h.Time, h.Threads,
+			base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.BcryptCost)
+	return string(hash), err
+}
+
+// Verify compares the password with the stored hash. needsRehash is set when the hash was
+// made by another algorithm or weaker parameters than the current ones, only for a matching password.
+func (h *Hasher) Verify(password, hash string) (match bool, needsRehash bool, err error) {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		params, salt, key, err := decodeArgon2id(hash)
+		if err != nil {
+			return false, false, err
+		}
+		computed := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+		if subtle.ConstantTimeCompare(computed, key) != 1 {
+			return false, false, nil
+		}
+		return true, h.Algorithm != Argon2id || params.Memory < h.Memory || params.Time < h.Time || params.Threads < h.Threads, nil
+	case strings.HasPrefix(hash, "$2"):
+		if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+			if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+				return false, false, nil
+			}
+			return false, false, err
+		}
+		cost, err := bcrypt.Cost([]byte(hash))
+		if err != nil {
+			return true, true, nil
+		}
+		return true, h.Algorithm != Bcrypt || cost < h.BcryptCost, nil
+	}
+	return false, false, ErrUnknownHash
+}
+
+func decodeArgon2id(hash string) (params Hasher, salt, key []byte, err error) {
+	// "", "argon2id", "v=19", "m=..,t=..,p=..", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, ErrUnknownHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrUnknownHash
+	}
+	// A stored hash with a zero time or threads would panic in argon2.IDKey
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil ||
+		params.Time < 1 || params.Threads < 1 {
+		return params, nil, nil, ErrUnknownHash
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return params, nil, nil, ErrUnknownHash
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(key) == 0 {
+		return params, nil, nil, ErrUnknownHash
+	}
+	return params, salt, key, nil
+}
+
+// Hash with the default hasher
+func Hash(password string) (string, error) {
+	return DefaultHasher().Hash(password)
+}
+
+// Verify with the default hasher
+func Verify(password, hash string) (match bool, needsRehash bool, err error) {
+	return DefaultHasher().Verify(password, hash)
+}
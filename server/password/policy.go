@@ -0,0 +1,149 @@
+// Password policy for the new passwords (signup and reset), configured under password in the config.
+// The breached passwords are read from a local file, nothing is sent over the network.
+package password
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Rules, sent to the frontend with the violation
+const (
+	RuleMinLength = "min_length"
+	RuleMaxLength = "max_length"
+	RuleLower     = "require_lower"
+	RuleUpper     = "require_upper"
+	RuleDigit     = "require_digit"
+	RuleSymbol    = "require_symbol"
+	RulePersonal  = "personal_info"
+	RuleBreached  = "breached"
+)
+
+// bcrypt only looks at the first 72 bytes
+const maxBytes = 72
+
+// Parts of the email or roll number shorter than this are not checked, too many false positives
+const minPersonalLength = 3
+
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// User is whatever we know about the owner of the password, empty fields are skipped
+type User struct {
+	Email  string
+	RollNo string
+}
+
+type Policy struct {
+	MinLength int
+	MaxLength int      // in bytes
+	Require   []string // character classes: lower, upper, digit, symbol
+	breached  map[string]struct{}
+}
+
+var characterClasses = map[string]struct {
+	rule    string
+	message string
+	match   func(rune) bool
+}{
+	"lower":  {RuleLower, "Password must contain a lowercase letter", unicode.IsLower},
+	"upper":  {RuleUpper, "Password must contain an uppercase letter", unicode.IsUpper},
+	"digit":  {RuleDigit, "Password must contain a digit", unicode.IsDigit},
+	"symbol": {RuleSymbol, "Password must contain a symbol", func(r rune) bool { return unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r) }},
+}
+
+var policy *Policy
+
+// LoadPolicy reads the policy from the config, called once at startup before any password is checked
+func LoadPolicy() error {
+	viper.SetDefault("password.min_length", 8)
+	p := &Policy{
+		MinLength: viper.GetInt("password.min_length"),
+		MaxLength: maxBytes,
+		Require:   viper.GetStringSlice("password.require"),
+	}
+	if max := viper.GetInt("password.max_length"); max > 0 && max < maxBytes {
+		p.MaxLength = max
+	}
+	for _, class := range p.Require {
+		if _, ok := characterClasses[class]; !ok {
+			return fmt.Errorf("unknown character class %q in password.require", class)
+		}
+	}
+	if path := viper.GetString("password.breached_list"); path != "" {
+		breached, err := LoadList(path)
+		if err != nil {
+			logrus.Errorf("Failed to load the breached passwords, the check is off: %v", err)
+		} else {
+			p.breached = breached
+			logrus.Infof("Loaded %d breached passwords", len(breached))
+		}
+	}
+	policy = p
+	return nil
+}
+
+// Default is the policy loaded by LoadPolicy
+func Default() *Policy {
+	return policy
+}
+
+// LoadList reads the passwords one per line, blank lines and the ones starting with # are skipped
+func LoadList(path string) (map[string]struct{}, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	list := make(map[string]struct{})
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		list[strings.ToLower(line)] = struct{}{}
+	}
+	return list, scanner.Err()
+}
+
+// Check returns every rule the password breaks, nil when it is fine
+func (p *Policy) Check(password string, user User) []Violation {
+	var violations []Violation
+	if utf8.RuneCountInString(password) < p.MinLength {
+		violations = append(violations, Violation{RuleMinLength, fmt.Sprintf("Password must be at least %d characters long", p.MinLength)})
+	}
+	if len(password) > p.MaxLength {
+		violations = append(violations, Violation{RuleMaxLength, fmt.Sprintf("Password must be at most %d bytes long", p.MaxLength)})
+	}
+	for _, name := range p.Require {
+		class := characterClasses[name]
+		if !strings.ContainsFunc(password, class.match) {
+			violations = append(violations, Violation{class.rule, class.message})
+		}
+	}
+
+	lower := strings.ToLower(password)
+	local, _, _ := strings.Cut(strings.ToLower(user.Email), "@")
+	for _, part := range []string{local, strings.ToLower(user.RollNo)} {
+		if len(part) >= minPersonalLength && strings.Contains(lower, part) {
+			violations = append(violations, Violation{RulePersonal, "Password must not contain your email or roll number"})
+			break
+		}
+	}
+
+	if _, found := p.breached[lower]; found {
+		violations = append(violations, Violation{RuleBreached, "This password is too common or was found in a data breach, please choose another"})
+	}
+	return violations
+}
@@ -0,0 +1,146 @@
+// Failure based throttling for the auth endpoints (login, otp, forgot password)
+// Every failure is counted per key (email, ip...), once the threshold is crossed
+// the key is locked out, and the lockout doubles with every further failure.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Entry is the state of a single key
+type Entry struct {
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+// Store keeps the entries, the in process one is enough as long as a single instance is running.
+// Update must be atomic, the whole read-modify-write happens inside it.
+type Store interface {
+	Get(key string) (Entry, error)
+	Update(key string, fn func(*Entry)) (Entry, error)
+	Delete(key string) error
+}
+
+type Policy struct {
+	Threshold   int           // failures allowed before the first lockout
+	BaseLockout time.Duration // first lockout, doubled for every failure after that
+	MaxLockout  time.Duration
+	Window      time.Duration // failures are forgotten after this much quiet time
+}
+
+type Limiter struct {
+	name   string
+	policy Policy
+	store  Store // nil till the first use, see defaultStore
+}
+
+func New(name string, policy Policy) *Limiter {
+	return &Limiter{name: name, policy: policy}
+}
+
+// WithStore is used for a limiter which does not use the configured store
+func (l *Limiter) WithStore(store Store) *Limiter {
+	l.store = store
+	return l
+}
+
+func (l *Limiter) Name() string {
+	return l.name
+}
+
+func (l *Limiter) storage() Store {
+	if l.store != nil {
+		return l.store
+	}
+	return defaultStore()
+}
+
+func (l *Limiter) key(key string) string {
+	return l.name + ":" + key
+}
+
+// RetryAfter tells how long the key is locked for, zero when it is not locked
+func (l *Limiter) RetryAfter(key string) (time.Duration, error) {
+	entry, err := l.storage().Get(l.key(key))
+	if err != nil {
+		return 0, err
+	}
+	return remaining(entry.LockedUntil), nil
+}
+
+// Fail records a failure, the returned duration is non zero if the key is locked now
+func (l *Limiter) Fail(key string) (time.Duration, error) {
+	now := time.Now()
+	entry, err := l.storage().Update(l.key(key), func(e *Entry) {
+		if now.Sub(e.LastFailure) > l.policy.Window && now.After(e.LockedUntil) {
+			e.Failures = 0
+		}
+		e.Failures++
+		e.LastFailure = now
+		if over := e.Failures - l.policy.Threshold; over >= 0 {
+			e.LockedUntil = now.Add(l.lockout(over))
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+	return remaining(entry.LockedUntil), nil
+}
+
+// Failures counted for the key in the current window
+func (l *Limiter) Failures(key string) (int, error) {
+	entry, err := l.storage().Get(l.key(key))
+	if err != nil {
+		return 0, err
+	}
+	if time.Since(entry.LastFailure) > l.policy.Window && time.Now().After(entry.LockedUntil) {
+		return 0, nil
+	}
+	return entry.Failures, nil
+}
+
+// Reset forgets the failures, call it after a successful attempt
+func (l *Limiter) Reset(key string) error {
+	return l.storage().Delete(l.key(key))
+}
+
+func (l *Limiter) lockout(over int) time.Duration {
+	lockout := float64(l.policy.BaseLockout) * math.Pow(2, float64(over))
+	if lockout > float64(l.policy.MaxLockout) {
+		return l.policy.MaxLockout
+	}
+	return time.Duration(lockout)
+}
+
+func remaining(until time.Time) time.Duration {
+	if d := time.Until(until); d > 0 {
+		return d
+	}
+	return 0
+}
+
+var (
+	store     Store
+	storeOnce sync.Once
+)
+
+// configured store (ratelimit.store), built on the first use so that viper and the db are ready
+func defaultStore() Store {
+	storeOnce.Do(func() {
+		switch viper.GetString("ratelimit.store") {
+		case "database":
+			store = DatabaseStore{}
+		case "memory", "":
+			store = NewMemoryStore(24 * time.Hour)
+		default:
+			logrus.Fatalf("Unknown rate limit store %q", viper.GetString("ratelimit.store"))
+		}
+	})
+	return store
+}
@@ -0,0 +1,47 @@
+package ratelimit
+
+import (
+	"compass/connections"
+	"compass/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DatabaseStore shares the entries between instances through the rate_limits table
+type DatabaseStore struct{}
+
+func (DatabaseStore) Get(key string) (Entry, error) {
+	var row model.RateLimit
+	result := connections.DB.Where("key = ?", key).Limit(1).Find(&row)
+	if result.Error != nil {
+		return Entry{}, result.Error
+	}
+	return Entry{Failures: row.Failures, LastFailure: row.LastFailure, LockedUntil: row.LockedUntil}, nil
+}
+
+func (DatabaseStore) Update(key string, fn func(*Entry)) (Entry, error) {
+	var entry Entry
+	err := connections.DB.Transaction(func(tx *gorm.DB) error {
+		// Make sure the row exists, so that it can be locked
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.RateLimit{Key: key}).Error; err != nil {
+			return err
+		}
+		var row model.RateLimit
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", key).First(&row).Error; err != nil {
+			return err
+		}
+		entry = Entry{Failures: row.Failures, LastFailure: row.LastFailure, LockedUntil: row.LockedUntil}
+		fn(&entry)
+		return tx.Model(&model.RateLimit{}).Where("key = ?", key).Updates(map[string]interface{}{
+			"failures":     entry.Failures,
+			"last_failure": entry.LastFailure,
+			"locked_until": entry.LockedUntil,
+		}).Error
+	})
+	return entry, err
+}
+
+func (DatabaseStore) Delete(key string) error {
+	return connections.DB.Where("key = ?", key).Delete(&model.RateLimit{}).Error
+}
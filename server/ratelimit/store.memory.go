@@ -0,0 +1,56 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps the entries in the process, lost on restart
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]Entry
+	ttl     time.Duration
+}
+
+// NewMemoryStore drops the entries which were not touched for ttl (and are not locked)
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	s := &MemoryStore{entries: map[string]Entry{}, ttl: ttl}
+	go s.sweep()
+	return s
+}
+
+func (s *MemoryStore) Get(key string) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.entries[key], nil
+}
+
+func (s *MemoryStore) Update(key string, fn func(*Entry)) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry := s.entries[key]
+	fn(&entry)
+	s.entries[key] = entry
+	return entry, nil
+}
+
+func (s *MemoryStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+func (s *MemoryStore) sweep() {
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.mu.Lock()
+		for key, entry := range s.entries {
+			if time.Since(entry.LastFailure) > s.ttl && time.Now().After(entry.LockedUntil) {
+				delete(s.entries, key)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
@@ -0,0 +1,79 @@
+package routing
+
+import (
+	"container/heap"
+	"errors"
+)
+
+var ErrNoRoute = errors.New("no route between the points")
+
+type queueItem struct {
+	node     int
+	priority float64 // cost so far + straight line distance to the goal
+}
+
+type priorityQueue []queueItem
+
+func (q priorityQueue) Len() int            { return len(q) }
+func (q priorityQueue) Less(i, j int) bool  { return q[i].priority < q[j].priority }
+func (q priorityQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *priorityQueue) Push(x interface{}) { *q = append(*q, x.(queueItem)) }
+func (q *priorityQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+// shortestPath runs A* over the edges open to the mode, the straight line distance never overestimates
+// so the path is the shortest. Returns the nodes from start to goal and the edges taken between them.
+func (g *Graph) shortestPath(start, goal int, mode Mode) ([]int, []edge, error) {
+	cost := map[int]float64{start: 0}
+	// previous node and the edge used to reach it
+	cameFrom := map[int]int{}
+	via := map[int]edge{}
+	done := map[int]bool{}
+
+	queue := &priorityQueue{{start, Distance(g.nodes[start], g.nodes[goal])}}
+	for queue.Len() > 0 {
+		current := heap.Pop(queue).(queueItem).node
+		if current == goal {
+			break
+		}
+		if done[current] {
+			continue // stale entry, a shorter one was handled already
+		}
+		done[current] = true
+		for _, e := range g.edges[current] {
+			if e.modes&mode == 0 || done[e.to] {
+				continue
+			}
+			next := cost[current] + e.length
+			if known, ok := cost[e.to]; ok && known <= next {
+				continue
+			}
+			cost[e.to] = next
+			cameFrom[e.to] = current
+			via[e.to] = e
+			heap.Push(queue, queueItem{e.to, next + Distance(g.nodes[e.to], g.nodes[goal])})
+		}
+	}
+	if _, ok := cost[goal]; !ok {
+		return nil, nil, ErrNoRoute
+	}
+
+	path := []int{goal}
+	var edges []edge
+	for n := goal; n != start; n = cameFrom[n] {
+		path = append(path, cameFrom[n])
+		edges = append(edges, via[n])
+	}
+	// Built from the goal, flip it
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	for i, j := 0, len(edges)-1; i < j; i, j = i+1, j-1 {
+		edges[i], edges[j] = edges[j], edges[i]
+	}
+	return path, edges, nil
+}
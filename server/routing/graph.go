@@ -0,0 +1,270 @@
+package routing
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+)
+
+type Mode uint8
+
+// Modes are bits, an edge may allow both
+const (
+	Walk Mode = 1 << iota
+	Cycle
+)
+
+// Average speeds in m/s, used for the ETA
+var speeds = map[Mode]float64{
+	Walk:  1.4, // ~5 km/h
+	Cycle: 4.2, // ~15 km/h
+}
+
+type Point struct {
+	Lat float64
+	Lng float64
+}
+
+type edge struct {
+	to     int
+	length float64 // metres
+	name   string
+	modes  Mode
+}
+
+// Graph of the campus paths, the nodes are the vertices of the LineStrings.
+// Ways meeting at a junction share the vertex in OSM, that is how they get connected.
+type Graph struct {
+	nodes []Point
+	edges [][]edge
+	grid  map[cell][]int // nodes by cell, for snapping
+}
+
+// ~110 m at the equator, snapping searches the rings of cells around the point
+const cellSize = 0.001
+
+type cell struct{ x, y int }
+
+func cellOf(p Point) cell {
+	return cell{int(math.Floor(p.Lng / cellSize)), int(math.Floor(p.Lat / cellSize))}
+}
+
+type geoJSON struct {
+	Features []struct {
+		Geometry struct {
+			Type        string          `json:"type"`
+			Coordinates json.RawMessage `json:"coordinates"`
+		} `json:"geometry"`
+		Properties map[string]interface{} `json:"properties"`
+	} `json:"features"`
+}
+
+// Load builds the graph from the LineStrings with a highway tag, the rest of the features are skipped
+func Load(path string) (*Graph, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var collection geoJSON
+	if err := json.Unmarshal(data, &collection); err != nil {
+		return nil, fmt.Errorf("invalid geojson: %w", err)
+	}
+
+	g := &Graph{grid: make(map[cell][]int)}
+	index := make(map[Point]int)
+	node := func(lng, lat float64) int {
+		// OSM has 7 decimals, rounding makes the shared vertices equal
+		p := Point{math.Round(lat*1e7) / 1e7, math.Round(lng*1e7) / 1e7}
+		if i, ok := index[p]; ok {
+			return i
+		}
+		index[p] = len(g.nodes)
+		g.nodes = append(g.nodes, p)
+		g.edges = append(g.edges, nil)
+		return len(g.nodes) - 1
+	}
+
+	for _, feature := range collection.Features {
+		if feature.Geometry.Type != "LineString" {
+			continue
+		}
+		tags := make(map[string]string)
+		for k, v := range feature.Properties {
+			if s, ok := v.(string); ok {
+				tags[k] = s
+			}
+		}
+		modes := wayModes(tags)
+		if modes == 0 {
+			continue
+		}
+		var coordinates [][]float64
+		if err := json.Unmarshal(feature.Geometry.Coordinates, &coordinates); err != nil {
+			return nil, fmt.Errorf("invalid LineString: %w", err)
+		}
+		oneway := tags["oneway"] == "yes"
+		for i := 1; i < len(coordinates); i++ {
+			if len(coordinates[i-1]) < 2 || len(coordinates[i]) < 2 {
+				return nil, fmt.Errorf("invalid LineString coordinate")
+			}
+			a := node(coordinates[i-1][0], coordinates[i-1][1])
+			b := node(coordinates[i][0], coordinates[i][1])
+			if a == b {
+				continue
+			}
+			length := Distance(g.nodes[a], g.nodes[b])
+			g.edges[a] = append(g.edges[a], edge{b, length, tags["name"], modes})
+			back := modes
+			if oneway {
+				back &^= Cycle // walking is fine both ways
+			}
+			if back != 0 {
+				g.edges[b] = append(g.edges[b], edge{a, length, tags["name"], back})
+			}
+		}
+	}
+	if len(g.nodes) == 0 {
+		return nil, fmt.Errorf("no paths in %s", path)
+	}
+	g.keepLargestComponent()
+	return g, nil
+}
+
+// wayModes decides who may use the way from its OSM tags
+func wayModes(tags map[string]string) Mode {
+	highway := tags["highway"]
+	switch highway {
+	case "", "motorway", "motorway_link", "construction", "proposed", "platform":
+		return 0
+	}
+	allowed := func(key string) bool {
+		switch tags[key] {
+		case "no":
+			return false
+		case "yes", "designated", "permissive", "destination":
+			return true
+		}
+		return tags["access"] != "no" && tags["access"] != "private"
+	}
+
+	var modes Mode
+	if allowed("foot") {
+		modes |= Walk
+	}
+	if allowed("bicycle") {
+		switch highway {
+		case "footway", "corridor", "steps", "pedestrian":
+			// Only when the bicycle tag says so
+			if tags["bicycle"] == "yes" || tags["bicycle"] == "designated" {
+				modes |= Cycle
+			}
+		default:
+			modes |= Cycle
+		}
+	}
+	return modes
+}
+
+// keepLargestComponent indexes only the nodes connected to the main network,
+// a snap to a small island (a lone footway inside a compound) would never find a route
+func (g *Graph) keepLargestComponent() {
+	// One way edges count both ways here, it is only about being on the same network
+	links := make([][]int, len(g.nodes))
+	for n, edges := range g.edges {
+		for _, e := range edges {
+			links[n] = append(links[n], e.to)
+			links[e.to] = append(links[e.to], n)
+		}
+	}
+	component := make([]int, len(g.nodes))
+	for i := range component {
+		component[i] = -1
+	}
+	var sizes []int
+	for start := range g.nodes {
+		if component[start] != -1 {
+			continue
+		}
+		id := len(sizes)
+		sizes = append(sizes, 0)
+		stack := []int{start}
+		component[start] = id
+		for len(stack) > 0 {
+			n := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			sizes[id]++
+			for _, next := range links[n] {
+				if component[next] == -1 {
+					component[next] = id
+					stack = append(stack, next)
+				}
+			}
+		}
+	}
+	largest := 0
+	for id, size := range sizes {
+		if size > sizes[largest] {
+			largest = id
+		}
+	}
+	for i, p := range g.nodes {
+		if component[i] == largest {
+			c := cellOf(p)
+			g.grid[c] = append(g.grid[c], i)
+		}
+	}
+}
+
+// Nearest node usable by the mode, and its distance in metres. Returns -1 when nothing is within maxDistance.
+func (g *Graph) Nearest(p Point, mode Mode, maxDistance float64) (int, float64) {
+	best, bestDistance := -1, math.Inf(1)
+	center := cellOf(p)
+	// Width of a cell in metres, the longitude side is the shorter one
+	cellWidth := cellSize * 111320 * math.Cos(p.Lat*math.Pi/180)
+	// Grow the search ring till no unseen cell can be closer than the best node
+	rings := int(math.Ceil(maxDistance/cellWidth)) + 1
+	for r := 0; r <= rings; r++ {
+		for x := center.x - r; x <= center.x+r; x++ {
+			for y := center.y - r; y <= center.y+r; y++ {
+				if r > 0 && x != center.x-r && x != center.x+r && y != center.y-r && y != center.y+r {
+					continue // inner cells are done
+				}
+				for _, n := range g.grid[cell{x, y}] {
+					if !g.usable(n, mode) {
+						continue
+					}
+					if d := Distance(p, g.nodes[n]); d < bestDistance {
+						best, bestDistance = n, d
+					}
+				}
+			}
+		}
+		if best != -1 && bestDistance < float64(r)*cellWidth {
+			break
+		}
+	}
+	if best == -1 || bestDistance > maxDistance {
+		return -1, 0
+	}
+	return best, bestDistance
+}
+
+func (g *Graph) usable(n int, mode Mode) bool {
+	for _, e := range g.edges[n] {
+		if e.modes&mode != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Great circle distance in metres
+func Distance(a, b Point) float64 {
+	const earthRadius = 6371000
+	dLat := (b.Lat - a.Lat) * math.Pi / 180
+	dLng := (b.Lng - a.Lng) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(a.Lat*math.Pi/180)*math.Cos(b.Lat*math.Pi/180)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
@@ -0,0 +1,113 @@
+// Walking and cycling directions on campus, over the paths of the OSM export (parser/locations.geojson).
+// The graph is held in memory, it is small enough for A* on every request.
+package routing
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// A point further than this from every path is not on campus
+const maxSnapDistance = 500.0
+
+var ErrOffNetwork = errors.New("point is too far from the campus paths")
+
+func ParseMode(s string) (Mode, error) {
+	switch s {
+	case "", "walk":
+		return Walk, nil
+	case "cycle":
+		return Cycle, nil
+	}
+	return 0, fmt.Errorf("unknown mode %q, use walk or cycle", s)
+}
+
+func (m Mode) String() string {
+	if m == Cycle {
+		return "cycle"
+	}
+	return "walk"
+}
+
+// Route is a GeoJSON Feature, the LineString goes from the origin to the destination
+type Route struct {
+	Type       string          `json:"type"`
+	Geometry   lineString      `json:"geometry"`
+	Properties RouteProperties `json:"properties"`
+}
+
+type lineString struct {
+	Type        string       `json:"type"`
+	Coordinates [][2]float64 `json:"coordinates"` // lng, lat
+}
+
+type RouteProperties struct {
+	Mode     string  `json:"mode"`
+	Distance float64 `json:"distance"` // metres
+	Duration float64 `json:"duration"` // seconds
+	Steps    []Step  `json:"steps"`
+}
+
+var (
+	graph     *Graph
+	graphErr  error
+	graphOnce sync.Once
+)
+
+// Default loads the graph from routing.graph on the first use
+func Default() (*Graph, error) {
+	graphOnce.Do(func() {
+		path := viper.GetString("routing.graph")
+		if path == "" {
+			path = "./parser/locations.geojson"
+		}
+		graph, graphErr = Load(path)
+		if graphErr != nil {
+			logrus.Errorf("Failed to load the routing graph from %s: %v", path, graphErr)
+			return
+		}
+		logrus.Infof("Loaded the routing graph with %d nodes", len(graph.nodes))
+	})
+	return graph, graphErr
+}
+
+// Route finds the shortest route between the points for the mode
+func (g *Graph) Route(from, to Point, mode Mode) (Route, error) {
+	start, startGap := g.Nearest(from, mode, maxSnapDistance)
+	goal, goalGap := g.Nearest(to, mode, maxSnapDistance)
+	if start == -1 || goal == -1 {
+		return Route{}, ErrOffNetwork
+	}
+	path, edges, err := g.shortestPath(start, goal, mode)
+	if err != nil {
+		return Route{}, err
+	}
+
+	coordinates := [][2]float64{{from.Lng, from.Lat}}
+	for _, n := range path {
+		coordinates = append(coordinates, [2]float64{g.nodes[n].Lng, g.nodes[n].Lat})
+	}
+	coordinates = append(coordinates, [2]float64{to.Lng, to.Lat})
+
+	// The gaps to the snapped nodes are covered in a straight line
+	total := startGap + goalGap
+	for _, e := range edges {
+		total += e.length
+	}
+	speed := speeds[mode]
+	return Route{
+		Type:     "Feature",
+		Geometry: lineString{Type: "LineString", Coordinates: coordinates},
+		Properties: RouteProperties{
+			Mode:     mode.String(),
+			Distance: math.Round(total),
+			Duration: math.Round(total / speed),
+			Steps:    g.steps(from, path, edges, startGap, goalGap, speed),
+		},
+	}, nil
+}
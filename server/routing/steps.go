@@ -0,0 +1,130 @@
+package routing
+
+import (
+	"fmt"
+	"math"
+)
+
+// Step of the turn by turn directions, the distance is till the next step
+type Step struct {
+	Instruction string     `json:"instruction"`
+	Maneuver    string     `json:"maneuver"`           // depart, turn, continue, arrive
+	Modifier    string     `json:"modifier,omitempty"` // left, slight right, sharp left, uturn, straight
+	Name        string     `json:"name,omitempty"`     // of the path, when OSM has one
+	Distance    float64    `json:"distance"`           // metres
+	Duration    float64    `json:"duration"`           // seconds
+	Location    [2]float64 `json:"location"`           // lng, lat of the maneuver
+}
+
+// A bend sharper than this at a junction is announced even on the same path
+const turnThreshold = 45.0
+
+// steps groups the edges into the maneuvers: a new step starts where the path name changes,
+// or at a junction with a real turn. Bends of a single path are not announced.
+func (g *Graph) steps(origin Point, path []int, edges []edge, startGap, goalGap, speed float64) []Step {
+	if len(edges) == 0 {
+		return []Step{
+			{Instruction: "Head to your destination", Maneuver: "depart", Distance: math.Round(startGap + goalGap), Duration: math.Round((startGap + goalGap) / speed), Location: [2]float64{origin.Lng, origin.Lat}},
+			g.arrive(path[0]),
+		}
+	}
+
+	first := edges[0]
+	steps := []Step{{
+		Instruction: fmt.Sprintf("Head %s%s", compass(g.bearing(path[0], path[1])), onto(" on ", first.name)),
+		Maneuver:    "depart",
+		Name:        first.name,
+		Distance:    startGap,
+		Location:    [2]float64{origin.Lng, origin.Lat},
+	}}
+	for i, e := range edges {
+		if i > 0 {
+			previous := edges[i-1]
+			turn := turnAngle(g.bearing(path[i-1], path[i]), g.bearing(path[i], path[i+1]))
+			junction := len(g.edges[path[i]]) > 2
+			if e.name != previous.name || (junction && math.Abs(turn) >= turnThreshold) {
+				steps = append(steps, g.turn(path[i], turn, e.name))
+			}
+		}
+		steps[len(steps)-1].Distance += e.length
+	}
+	steps[len(steps)-1].Distance += goalGap
+
+	for i := range steps {
+		steps[i].Distance = math.Round(steps[i].Distance)
+		steps[i].Duration = math.Round(steps[i].Distance / speed)
+	}
+	return append(steps, g.arrive(path[len(path)-1]))
+}
+
+func (g *Graph) turn(n int, angle float64, name string) Step {
+	modifier := turnModifier(angle)
+	step := Step{Maneuver: "turn", Modifier: modifier, Name: name, Location: g.location(n)}
+	switch modifier {
+	case "straight":
+		step.Maneuver = "continue"
+		step.Instruction = "Continue straight" + onto(" onto ", name)
+	case "uturn":
+		step.Instruction = "Make a U-turn" + onto(" onto ", name)
+	default:
+		step.Instruction = "Turn " + modifier + onto(" onto ", name)
+	}
+	return step
+}
+
+func (g *Graph) arrive(n int) Step {
+	return Step{Instruction: "Arrive at your destination", Maneuver: "arrive", Location: g.location(n)}
+}
+
+func (g *Graph) location(n int) [2]float64 {
+	return [2]float64{g.nodes[n].Lng, g.nodes[n].Lat}
+}
+
+// Initial bearing from a to b in degrees, 0 is north and 90 east
+func (g *Graph) bearing(a, b int) float64 {
+	from, to := g.nodes[a], g.nodes[b]
+	lat1, lat2 := from.Lat*math.Pi/180, to.Lat*math.Pi/180
+	dLng := (to.Lng - from.Lng) * math.Pi / 180
+	y := math.Sin(dLng) * math.Cos(lat2)
+	x := math.Cos(lat1)*math.Sin(lat2) - math.Sin(lat1)*math.Cos(lat2)*math.Cos(dLng)
+	return math.Mod(math.Atan2(y, x)*180/math.Pi+360, 360)
+}
+
+// turnAngle is in (-180, 180], negative for a left turn
+func turnAngle(in, out float64) float64 {
+	angle := math.Mod(out-in+540, 360) - 180
+	if angle == -180 {
+		return 180
+	}
+	return angle
+}
+
+func turnModifier(angle float64) string {
+	side := "right"
+	if angle < 0 {
+		side = "left"
+	}
+	switch a := math.Abs(angle); {
+	case a < 20:
+		return "straight"
+	case a < 60:
+		return "slight " + side
+	case a < 135:
+		return side
+	case a < 170:
+		return "sharp " + side
+	}
+	return "uturn"
+}
+
+func compass(bearing float64) string {
+	directions := []string{"north", "northeast", "east", "southeast", "south", "southwest", "west", "northwest"}
+	return directions[int(math.Round(bearing/45))%8]
+}
+
+func onto(preposition, name string) string {
+	if name == "" {
+		return ""
+	}
+	return preposition + name
+}
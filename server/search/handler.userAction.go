@@ -123,12 +123,21 @@ func deleteProfileData(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to delete profile"})
 		return
 	}
+	target := userID.(uuid.UUID)
+	middleware.RecordAuthEvent(c, model.AuthEvent{Type: model.EventProfileDelete, Outcome: model.OutcomeSuccess, TargetID: &target})
 	middleware.ClearAuthCookie(c)
 	c.JSON(http.StatusOK, gin.H{"message": "User profile data deleted successfully"})
 }
 
 func toggleVisibility(c *gin.Context) {
 	userID, _ := c.Get("userID")
+	// The new access token goes to the same session, the route is session only
+	value, _ := c.Get("sessionID")
+	sessionID, ok := value.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "No session found"})
+		return
+	}
 	var input toggleVisibilityRequest
 	// Request Validation
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -179,17 +188,19 @@ func toggleVisibility(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to update visibility at the moment."})
 		return
 	}
+	target := userID.(uuid.UUID)
+	middleware.RecordAuthEvent(c, model.AuthEvent{Type: model.EventVisibilityChange, Outcome: model.OutcomeSuccess, TargetID: &target,
+		Reason: fmt.Sprintf("visibility set to %t", *input.Visibility)})
 
 	// TODO: We can extract out this token refresh logic
-	// Clear the old cookie with visibility true
-	middleware.ClearAuthCookie(c)
-	token, err := middleware.GenerateAccessToken(userID.(uuid.UUID))
-	ref_token, _ := middleware.GenerateRefreshToken(userID.(uuid.UUID))
+	// Replace the old access token having the previous visibility, the session (refresh token) stays the same
+	token, err := middleware.GenerateAccessToken(userID.(uuid.UUID), sessionID)
 	if err != nil {
+		middleware.ClearAuthCookie(c)
 		c.JSON(http.StatusOK, gin.H{"message": "visibility updated successfully, please login again to continue"})
+		return
 	}
 	middleware.SetAuthCookie(c, token)
-	middleware.SetRefreshCookie(c, ref_token)
 
 	c.JSON(http.StatusOK, gin.H{"message": "visibility updated successfully"})
 
@@ -2,16 +2,19 @@ package search
 
 import (
 	"compass/middleware"
+	"compass/model"
 
 	"github.com/gin-gonic/gin"
 )
 
 func Router(r *gin.Engine) {
     search := r.Group("/api/search")
-    search.Use(middleware.UserAuthenticator)
+    // Students only, visitors have no profile in the directory
+    search.Use(middleware.UserAuthenticator, middleware.Require(model.PermDirectoryView))
 
-    search.POST("/toggleVisibility", toggleVisibility)
-    search.DELETE("/", deleteProfileData)
+    // Account changes, not for personal access tokens
+    search.POST("/toggleVisibility", middleware.SessionOnly, toggleVisibility)
+    search.DELETE("/", middleware.SessionOnly, deleteProfileData)
 
     protected := search.Group("/") 
     protected.Use(middleware.CheckVisibility)
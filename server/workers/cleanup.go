@@ -4,9 +4,11 @@ import (
 	"compass/connections"
 	"compass/model"
 	"encoding/json"
+	"os"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
 	"gorm.io/gorm"
 )
 
@@ -19,6 +21,30 @@ func CleanupWorker() error {
 		if err := processUnverifiedUsers(); err != nil {
 			logrus.Errorf("Error processing unverified users: %v", err)
 		}
+		if err := processExpiredVisitors(); err != nil {
+			logrus.Errorf("Error processing expired visitors: %v", err)
+		}
+		if err := processExpiredSessions(); err != nil {
+			logrus.Errorf("Error processing expired sessions: %v", err)
+		}
+		if err := processStaleRateLimits(); err != nil {
+			logrus.Errorf("Error processing rate limits: %v", err)
+		}
+		if err := processExpiredTokens(); err != nil {
+			logrus.Errorf("Error processing one time tokens: %v", err)
+		}
+		if err := processExpiredAuthCodes(); err != nil {
+			logrus.Errorf("Error processing oauth codes: %v", err)
+		}
+		if err := processExpiredAccessTokens(); err != nil {
+			logrus.Errorf("Error processing access tokens: %v", err)
+		}
+		if err := processExpiredExports(); err != nil {
+			logrus.Errorf("Error processing data exports: %v", err)
+		}
+		if err := processOldAuthEvents(); err != nil {
+			logrus.Errorf("Error processing auth events: %v", err)
+		}
 	}
 	return nil
 }
@@ -32,6 +58,7 @@ func processUnverifiedUsers() error {
 	threshold := time.Now().Add(-1 * time.Hour)
 
 	// Exclude the users which are deleted via user itself
+	// Visitors never get a name from the OA data, they go once they expire (processExpiredVisitors)
 	result := connections.DB.
 		Joins("LEFT JOIN profiles ON profiles.user_id = users.user_id").
 		Preload("Profile").
@@ -40,10 +67,9 @@ func processUnverifiedUsers() error {
         AND users.email NOT LIKE 'deleted_%'
         AND (
             users.is_verified = false
-            OR profiles.name = ''
-            OR profiles.name IS NULL
+            OR (users.role <> ? AND (profiles.name = '' OR profiles.name IS NULL))
         )
-    `, threshold).
+    `, threshold, model.VisitorRole).
 		Find(&users)
 
 	if result.Error != nil {
@@ -83,46 +109,149 @@ func processUnverifiedUsers() error {
 			// For now, let's delete to ensure cleanup happens.
 		}
 
-		// TODO: (currently its too hard coded) better way for this delete user and related data in a transaction
-		if err := connections.DB.Transaction(func(tx *gorm.DB) error {
-			// 1. Delete associated images (bio pics)
-			if err := tx.Unscoped().Where("parent_asset_id = ? AND parent_asset_type = ?", user.UserID, "users").Delete(&model.Image{}).Error; err != nil {
-				return err
-			}
+		if err := deleteUser(user); err != nil {
+			logrus.Errorf("Failed to delete user %s: %v", user.UserID, err)
+		} else {
+			logrus.Infof("Deleted unverified user: %s", user.Email)
+		}
+	}
 
-			// 2. Delete any changelog entries for this user
-			if err := tx.Unscoped().Where("user_id = ?", user.UserID).Delete(&model.ChangeLog{}).Error; err != nil {
-				return err
-			}
+	return nil
+}
 
-			// 3. Nullify any contributed locations/reviews/notices (unlikely for unverified users)
-			if err := tx.Model(&model.Location{}).Where("contributed_by = ?", user.UserID).Update("contributed_by", nil).Error; err != nil {
-				return err
-			}
-			if err := tx.Model(&model.Review{}).Where("contributed_by = ?", user.UserID).Update("contributed_by", nil).Error; err != nil {
-				return err
-			}
-			if err := tx.Model(&model.Notice{}).Where("contributed_by = ?", user.UserID).Update("contributed_by", nil).Error; err != nil {
-				return err
-			}
+// TODO: (currently its too hard coded) better way for this delete user and related data in a transaction
+func deleteUser(user model.User) error {
+	return connections.DB.Transaction(func(tx *gorm.DB) error {
+		// 1. Delete associated images (bio pics)
+		if err := tx.Unscoped().Where("parent_asset_id = ? AND parent_asset_type = ?", user.UserID, "users").Delete(&model.Image{}).Error; err != nil {
+			return err
+		}
 
-			// 4. Delete the profile (due to foreign key constraint)
-			if err := tx.Unscoped().Where("user_id = ?", user.UserID).Delete(&model.Profile{}).Error; err != nil {
-				return err
-			}
+		// 2. Delete any changelog entries for this user
+		if err := tx.Unscoped().Where("user_id = ?", user.UserID).Delete(&model.ChangeLog{}).Error; err != nil {
+			return err
+		}
 
-			// 5. Finally delete the user
-			if err := tx.Unscoped().Delete(&user).Error; err != nil {
-				return err
-			}
+		// 3. Nullify any contributed locations/reviews/notices (unlikely for unverified users)
+		if err := tx.Model(&model.Location{}).Where("contributed_by = ?", user.UserID).Update("contributed_by", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.Review{}).Where("contributed_by = ?", user.UserID).Update("contributed_by", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.Notice{}).Where("contributed_by = ?", user.UserID).Update("contributed_by", nil).Error; err != nil {
+			return err
+		}
 
-			return nil
-		}); err != nil {
-			logrus.Errorf("Failed to delete user %s: %v", user.UserID, err)
-		} else {
-			logrus.Infof("Deleted unverified user: %s", user.Email)
+		// 4. Delete the profile (due to foreign key constraint)
+		if err := tx.Unscoped().Where("user_id = ?", user.UserID).Delete(&model.Profile{}).Error; err != nil {
+			return err
 		}
+
+		// 5. Finally delete the user
+		if err := tx.Unscoped().Delete(&user).Error; err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// Visitor accounts past their expiry, they can not login anymore and the admins did not extend them
+func processExpiredVisitors() error {
+	var users []model.User
+	if err := connections.DB.
+		Where("role = ? AND expires_at < ?", model.VisitorRole, time.Now()).
+		Find(&users).Error; err != nil {
+		return err
 	}
+	for _, user := range users {
+		job := MailJob{
+			Type: "visitor_expired",
+			To:   user.Email,
+			Data: map[string]interface{}{
+				"username": user.Email,
+			},
+		}
+		if payload, err := json.Marshal(job); err == nil {
+			if err := PublishJob(payload, "mail"); err != nil {
+				logrus.Errorf("Failed to publish mail job for user %s: %v", user.UserID, err)
+			}
+		}
+		if err := deleteUser(user); err != nil {
+			logrus.Errorf("Failed to delete visitor %s: %v", user.UserID, err)
+			continue
+		}
+		logrus.Infof("Deleted expired visitor: %s", user.Email)
+	}
+	return nil
+}
+
+// Remove the sessions which can never be used again
+func processExpiredSessions() error {
+	// Revoked sessions are kept for a day, helpful while looking into a reported token reuse
+	result := connections.DB.
+		Where("expires_at < ? OR revoked_at < ?", time.Now(), time.Now().Add(-24*time.Hour)).
+		Delete(&model.Session{})
+	if result.Error != nil {
+		return result.Error
+	}
+	logrus.Infof("Removed %d expired sessions", result.RowsAffected)
+	return nil
+}
+
+// Rate limit entries of the database store, failures are forgotten after an hour anyway
+func processStaleRateLimits() error {
+	return connections.DB.
+		Where("last_failure < ? AND locked_until < ?", time.Now().Add(-24*time.Hour), time.Now()).
+		Delete(&model.RateLimit{}).Error
+}
+
+// One time tokens which are consumed or can not be used anymore
+func processExpiredTokens() error {
+	return connections.DB.
+		Where("expires_at < ? OR consumed_at < ?", time.Now(), time.Now().Add(-24*time.Hour)).
+		Delete(&model.OneTimeToken{}).Error
+}
+
+// Authorization codes which were never exchanged
+func processExpiredAuthCodes() error {
+	return connections.DB.Where("expires_at < ?", time.Now()).Delete(&model.OAuthCode{}).Error
+}
+
+// Personal access tokens expired or revoked a month back, kept till then for the token list and audits
+func processExpiredAccessTokens() error {
+	threshold := time.Now().AddDate(0, -1, 0)
+	return connections.DB.
+		Where("expires_at < ? OR revoked_at < ?", threshold, threshold).
+		Delete(&model.PersonalAccessToken{}).Error
+}
 
+// Archives whose download link expired, and the exports which failed or got stuck a day back
+func processExpiredExports() error {
+	var exports []model.DataExport
+	if err := connections.DB.
+		Where("expires_at < ? OR (status <> ? AND created_at < ?)", time.Now(), model.ExportReady, time.Now().Add(-24*time.Hour)).
+		Find(&exports).Error; err != nil {
+		return err
+	}
+	for _, export := range exports {
+		if err := os.Remove(ExportPath(export.ID)); err != nil && !os.IsNotExist(err) {
+			logrus.Errorf("Failed to remove export %s: %v", export.ID, err)
+			continue
+		}
+		if err := connections.DB.Delete(&export).Error; err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// Auth events are kept for audit.retention_days (180 by default)
+func processOldAuthEvents() error {
+	days := viper.GetInt("audit.retention_days")
+	if days <= 0 {
+		days = 180
+	}
+	return connections.DB.Where("created_at < ?", time.Now().AddDate(0, 0, -days)).Delete(&model.AuthEvent{}).Error
+}
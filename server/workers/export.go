@@ -0,0 +1,265 @@
+package workers
+
+import (
+	"archive/zip"
+	"compass/connections"
+	"compass/model"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Archives are kept out of assets/public, they are only served through the download api
+const ExportDir = "./assets/exports"
+
+// Life of the download link, the archive is removed by the cleanup worker after it
+const exportLinkExpiry = 48 * time.Hour
+
+// Publish attempts of the mail with the link, the export is kept ready if all of them fail
+const exportMailAttempts = 3
+
+// Uploaded images of the user, looked up in this order
+var exportImageDirs = []string{"./assets/public", "./assets/tmp"}
+
+func ExportPath(exportID uuid.UUID) string {
+	return filepath.Join(ExportDir, fmt.Sprintf("%s.zip", exportID))
+}
+
+// ExportTokenHash is stored instead of the token of the download link
+func ExportTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func ExportWorker() error {
+	logrus.Info("Export worker is up and running...")
+	msgs, err := connections.MQChannel.Consume(
+		viper.GetString("rabbitmq.exportqueue"), // queue
+		"",    // consumer tag
+		false, // autoAck
+		false, // exclusive
+		false, // noLocal
+		false, // noWait
+		nil,   // args
+	)
+	if err != nil {
+		return err
+	}
+	for task := range msgs {
+		var job ExportJob
+		if err := json.Unmarshal(task.Body, &job); err != nil {
+			logrus.Errorf("Invalid export job format: %v", err)
+			task.Nack(false, false) // don't requeue malformed messages
+			continue
+		}
+		mail, err := processExport(job.ExportID)
+		if err != nil {
+			logrus.Errorf("Export failed for\nID: %s\nError: %v", job.ExportID, err)
+			connections.DB.Model(&model.DataExport{}).Where("id = ?", job.ExportID).Update("status", model.ExportFailed)
+			os.Remove(ExportPath(job.ExportID))
+			task.Nack(false, false)
+			continue
+		}
+		if err := publishExportMail(mail); err != nil {
+			logrus.Errorf("Failed to mail the export link\nID: %s\nError: %v", job.ExportID, err)
+			// The archive is built, the redelivered job only mails a new link
+			task.Nack(false, true)
+			continue
+		}
+		task.Ack(false)
+	}
+	return fmt.Errorf("export worker channel closed unexpectedly")
+}
+
+// processExport builds the archive of a pending export and returns the mail with its link.
+// A ready export was built before but its mail was not sent, only the link is issued again.
+func processExport(exportID uuid.UUID) (MailJob, error) {
+	var export model.DataExport
+	if err := connections.DB.First(&export, "id = ? AND status IN ?", exportID, []string{model.ExportPending, model.ExportReady}).Error; err != nil {
+		return MailJob{}, err
+	}
+	var user model.User
+	if err := connections.DB.Preload("Profile").First(&user, "user_id = ?", export.UserID).Error; err != nil {
+		return MailJob{}, err
+	}
+
+	token, err := exportToken()
+	if err != nil {
+		return MailJob{}, err
+	}
+	updates := map[string]interface{}{"token_hash": ExportTokenHash(token)}
+	var expiresAt time.Time
+	if export.Status == model.ExportReady && export.ExpiresAt != nil {
+		expiresAt = *export.ExpiresAt
+	} else {
+		size, err := writeExport(export, user)
+		if err != nil {
+			return MailJob{}, err
+		}
+		now := time.Now()
+		expiresAt = now.Add(exportLinkExpiry)
+		updates["status"] = model.ExportReady
+		updates["size"] = size
+		updates["completed_at"] = now
+		updates["expires_at"] = expiresAt
+	}
+	if err := connections.DB.Model(&export).Updates(updates).Error; err != nil {
+		return MailJob{}, err
+	}
+
+	// Opens the download page of the frontend, the link is not single use
+	link := fmt.Sprintf("%s/profile/export?id=%s&token=%s", viper.GetString("frontend_url"), export.ID, token)
+	return MailJob{
+		Type: "data_export",
+		To:   user.Email,
+		Data: map[string]interface{}{
+			"link":    link,
+			"expires": expiresAt.Format("02 Jan 2006, 03:04 PM"),
+		},
+	}, nil
+}
+
+// publishExportMail retries the publish with a growing wait, which also slows down the requeued job
+func publishExportMail(job MailJob) error {
+	payload, _ := json.Marshal(job)
+	var err error
+	for attempt := 1; attempt <= exportMailAttempts; attempt++ {
+		if err = PublishJob(payload, model.MailQueue); err == nil {
+			return nil
+		}
+		time.Sleep(time.Duration(attempt) * time.Second)
+	}
+	return err
+}
+
+// writeExport builds the zip next to its final path and moves it there once complete, returns its size
+func writeExport(export model.DataExport, user model.User) (int64, error) {
+	if err := os.MkdirAll(ExportDir, 0o700); err != nil {
+		return 0, err
+	}
+	tmpPath := ExportPath(export.ID) + ".part"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmpPath) // no-op after the rename
+	defer file.Close()
+
+	archive := zip.NewWriter(file)
+	if err := addExportData(archive, user); err != nil {
+		return 0, err
+	}
+	if err := archive.Close(); err != nil {
+		return 0, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if err := file.Close(); err != nil {
+		return 0, err
+	}
+	return info.Size(), os.Rename(tmpPath, ExportPath(export.ID))
+}
+
+func addExportData(archive *zip.Writer, user model.User) error {
+	db := connections.DB
+	// Hash of the password and the two factor secret are not personal data, they are never sent out
+	user.Password = ""
+	user.TOTPSecret = ""
+
+	var locations []model.Location
+	if err := db.Preload("CoverPic").Preload("BioPics").Where("contributed_by = ?", user.UserID).Find(&locations).Error; err != nil {
+		return err
+	}
+	var reviews []model.Review
+	if err := db.Preload("Images").Where("contributed_by = ?", user.UserID).Find(&reviews).Error; err != nil {
+		return err
+	}
+	var notices []model.Notice
+	if err := db.Preload("CoverPic").Where("contributed_by = ?", user.UserID).Find(&notices).Error; err != nil {
+		return err
+	}
+	var changes []model.ChangeLog
+	if err := db.Where("user_id = ?", user.UserID).Order("created_at").Find(&changes).Error; err != nil {
+		return err
+	}
+	var images []model.Image
+	if err := db.Where("owner_id = ?", user.UserID).Find(&images).Error; err != nil {
+		return err
+	}
+
+	for name, data := range map[string]interface{}{
+		"user.json":       user,
+		"locations.json":  locations,
+		"reviews.json":    reviews,
+		"notices.json":    notices,
+		"changelogs.json": changes,
+		"images.json":     images,
+	} {
+		if err := addJSON(archive, name, data); err != nil {
+			return err
+		}
+	}
+
+	for _, image := range images {
+		for _, dir := range exportImageDirs {
+			path := filepath.Join(dir, fmt.Sprintf("%s.webp", image.ImageID))
+			if err := addFile(archive, filepath.Join("images", filepath.Base(dir), filepath.Base(path)), path); err == nil {
+				break
+			} else if !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	pfp := filepath.Join("./assets/pfp", fmt.Sprintf("%s.webp", user.UserID))
+	if err := addFile(archive, "images/pfp/"+filepath.Base(pfp), pfp); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func addJSON(archive *zip.Writer, name string, data interface{}) error {
+	w, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(data)
+}
+
+// addFile copies the file at path into the archive, the os.IsNotExist error is returned as it is
+func addFile(archive *zip.Writer, name string, path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	// Images are already compressed
+	w, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, src)
+	return err
+}
+
+func exportToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
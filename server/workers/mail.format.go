@@ -33,8 +33,20 @@ func FormatMail(job MailJob) (MailContent, error) {
 		return formatGenericNotice(job)
 	case "account_deletion":
 		return formatAccountDeletionEmail(job)
+	case "visitor_expired":
+		return formatVisitorExpiredEmail(job)
+	case "magic_link":
+		return formatMagicLinkEmail(job)
+	case "data_export":
+		return formatDataExportEmail(job)
 	case "password_reset":
 		return formatPasswordResetEmail(job)
+	case "email_change":
+		return formatEmailChangeEmail(job)
+	case "email_change_notice":
+		return formatEmailChangeNotice(job)
+	case "location_review":
+		return formatLocationReviewEmail(job)
 	default:
 		return MailContent{}, fmt.Errorf("unknown mail type: %s", job.Type)
 	}
@@ -190,6 +202,27 @@ func formatAccountDeletionEmail(job MailJob) (MailContent, error) {
 	}, nil
 }
 
+func formatVisitorExpiredEmail(job MailJob) (MailContent, error) {
+	data := map[string]interface{}{
+		"Username": job.Data["username"],
+	}
+	tmpl := `
+		<h2>Hello {{.Username}},</h2>
+		<p>Your visitor account has expired and has been deleted along with its bookmarks.</p>
+		<p>If you visit the campus again, ask your host for a new invite and sign up again.</p>
+	`
+	body, err := renderTemplate(tmpl, data)
+	if err != nil {
+		return MailContent{}, err
+	}
+	return MailContent{
+		To:      job.To,
+		Subject: "Visitor Account Expired",
+		Body:    body,
+		IsHTML:  true,
+	}, nil
+}
+
 func formatPasswordResetEmail(job MailJob) (MailContent, error) {
 	// username := job.Data["username"]
 	token := job.Data["token"]
@@ -218,6 +251,101 @@ func formatPasswordResetEmail(job MailJob) (MailContent, error) {
 	}, nil
 }
 
+func formatMagicLinkEmail(job MailJob) (MailContent, error) {
+	data := map[string]interface{}{
+		"Link":    job.Data["link"],
+		"Minutes": job.Data["minutes"],
+	}
+	tmpl := `
+		<h2>Log In to Compass</h2>
+		<p>Click the link below to log in, it works only once and expires in {{.Minutes}} minutes:</p>
+		<p><a href="{{.Link}}">Log In</a></p>
+		<p>If you did not request this, please ignore this email. Your password is unchanged.</p>
+	`
+	body, err := renderTemplate(tmpl, data)
+	if err != nil {
+		return MailContent{}, err
+	}
+	return MailContent{
+		To:      job.To,
+		Subject: "Your Login Link",
+		Body:    body,
+		IsHTML:  true,
+	}, nil
+}
+
+func formatDataExportEmail(job MailJob) (MailContent, error) {
+	data := map[string]interface{}{
+		"Link":    job.Data["link"],
+		"Expires": job.Data["expires"],
+	}
+	tmpl := `
+		<h2>Your Data is Ready</h2>
+		<p>The archive of your Compass data you requested is ready to download.</p>
+		<p><a href="{{.Link}}">Download your data</a></p>
+		<p>The link works till {{.Expires}}, after that please request a new export from your profile.</p>
+		<p>If you did not request this, please change your password.</p>
+	`
+	body, err := renderTemplate(tmpl, data)
+	if err != nil {
+		return MailContent{}, err
+	}
+	return MailContent{
+		To:      job.To,
+		Subject: "Your Compass Data Export",
+		Body:    body,
+		IsHTML:  true,
+	}, nil
+}
+
+// Sent to the new address, the change happens only after the link is opened
+func formatEmailChangeEmail(job MailJob) (MailContent, error) {
+	data := map[string]interface{}{
+		"Link": job.Data["link"],
+	}
+	tmpl := `
+		<h2>Confirm Your New Email</h2>
+		<p>You have requested to use this email for your Campus Compass account.</p>
+		<p>Click the link below to confirm it:</p>
+		<p><a href="{{.Link}}">Confirm Email</a></p>
+		<p>This link is valid for next 1 hour</p>
+		<p>If you did not request this, please ignore this email.</p>
+	`
+	body, err := renderTemplate(tmpl, data)
+	if err != nil {
+		return MailContent{}, err
+	}
+	return MailContent{
+		To:      job.To,
+		Subject: "Confirm Your New Email",
+		Body:    body,
+		IsHTML:  true,
+	}, nil
+}
+
+// Sent to the old address, as a heads up
+func formatEmailChangeNotice(job MailJob) (MailContent, error) {
+	data := map[string]interface{}{
+		"Email": job.Data["email"],
+	}
+	tmpl := `
+		<h2>Email Change Requested</h2>
+		<p>A request was made to change the email of your Campus Compass account to {{.Email}}.</p>
+		<p>The change happens only once the link sent to the new email is opened.</p>
+		<p>If this action was not taken by you, please reset your password right away.</p>
+	`
+	body, err := renderTemplate(tmpl, data)
+	if err != nil {
+		return MailContent{}, err
+	}
+	return MailContent{
+		To:      job.To,
+		Subject: "Email Change Requested",
+		Body:    body,
+		IsHTML:  true,
+	}, nil
+}
+
 // ========== Template Helper ==========
 
 func renderTemplate(tmpl string, data interface{}) (string, error) {
@@ -232,3 +360,48 @@ func renderTemplate(tmpl string, data interface{}) (string, error) {
 	}
 	return buf.String(), nil
 }
+
+// Outcome of the admin review of a contributed location: approved, rejected or changes_requested
+func formatLocationReviewEmail(job MailJob) (MailContent, error) {
+	data := map[string]interface{}{
+		"Location": job.Data["location"],
+		"Message":  job.Data["message"],
+	}
+	var subject, tmpl string
+	switch job.Data["outcome"] {
+	case "approved":
+		subject = "Your location is live!"
+		tmpl = `
+		<h2>Hi</h2>
+		<p>Thank you for your contribution, <strong>{{.Location}}</strong> has been approved and is now visible to everyone on the map.</p>
+		{{if .Message}}<p>Note from the admin: {{.Message}}</p>{{end}}
+	`
+	case "rejected":
+		subject = "Your location request was not approved"
+		tmpl = `
+		<h2>Hi</h2>
+		<p>Sorry, your request to add <strong>{{.Location}}</strong> was not approved.</p>
+		<p>Reason: {{.Message}}</p>
+	`
+	case "changes_requested":
+		subject = "Changes needed for your location request"
+		tmpl = `
+		<h2>Hi</h2>
+		<p>Thank you for submitting <strong>{{.Location}}</strong>, it needs a few changes before it can be published.</p>
+		<p>Requested changes: {{.Message}}</p>
+		<p>Update the request from your contributions on Compass and it goes back to the review.</p>
+	`
+	default:
+		return MailContent{}, fmt.Errorf("unknown location review outcome: %v", job.Data["outcome"])
+	}
+	body, err := renderTemplate(tmpl, data)
+	if err != nil {
+		return MailContent{}, err
+	}
+	return MailContent{
+		To:      job.To,
+		Subject: subject,
+		Body:    body,
+		IsHTML:  true,
+	}, nil
+}
@@ -17,6 +17,11 @@ type MailContent struct {
 	IsHTML  bool
 }
 
+// ExportJob asks for the zip of a DataExport row
+type ExportJob struct {
+	ExportID uuid.UUID `json:"export_id"`
+}
+
 type ModerationJob struct {
 	AssetID uuid.UUID `json:"asset_id"`
 	Type    string    `json:"type"`